@@ -0,0 +1,248 @@
+package discogs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Retry constants
+const (
+	MaxRetries    = 4
+	RetryDelay    = time.Second
+	MaxRetryDelay = 30 * time.Second
+)
+
+// StatusError описывает ответ Discogs API с кодом ошибки HTTP.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s: %d %s", e.URL, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Temporary сообщает, может ли повтор запроса быть успешным.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// CacheCounters описывает эффективность использования кэша ответов.
+type CacheCounters struct {
+	Hits        int64 `json:"hits"`
+	Misses      int64 `json:"misses"`
+	Revalidated int64 `json:"revalidated"`
+}
+
+// apiClient выполняет запросы к Discogs API с учетом бюджета запросов и повторяет запросы,
+// завершившиеся ошибкой 429, 5xx или ошибкой сети, с экспоненциальной задержкой.
+// При наличии кэша ответы сохраняются в нем, а устаревшие записи проверяются условными запросами.
+type apiClient struct {
+	counters   CacheCounters // первым полем для выравнивания 64-битных счетчиков
+	cache      Cache
+	headers    map[string]string
+	client     *http.Client
+	limiter    *rateLimiter
+	log        *log.Logger
+	retryDelay time.Duration
+}
+
+func newAPIClient(headers map[string]string, logger *log.Logger) *apiClient {
+	return &apiClient{
+		headers:    headers,
+		client:     &http.Client{Timeout: time.Minute},
+		limiter:    newRateLimiter(AnonymousRateLimit, logger),
+		log:        logger,
+		retryDelay: RetryDelay,
+	}
+}
+
+// DecodeJSON загружает ресурс и декодирует JSON данные ресурса.
+func (c *apiClient) DecodeJSON(url string, out interface{}) error {
+	data, err := c.Load(url)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Load возвращает содержимое тела ресурса из кэша или, при отсутствии в нем актуальной записи,
+// загружает ресурс, повторяя запрос при временных ошибках.
+func (c *apiClient) Load(url string) ([]byte, error) {
+	var entry *CacheEntry
+	if c.cache != nil {
+		var ok bool
+		if entry, ok = c.cache.Get(url); ok && entry.Fresh() {
+			atomic.AddInt64(&c.counters.Hits, 1)
+			return entry.Data, nil
+		}
+		atomic.AddInt64(&c.counters.Misses, 1)
+	}
+	resp, err := c.load(url, entry)
+	if err != nil {
+		return nil, err
+	}
+	if c.cache == nil {
+		return resp.data, nil
+	}
+	now := time.Now()
+	if resp.notModified && entry != nil {
+		atomic.AddInt64(&c.counters.Revalidated, 1)
+		updated := *entry
+		updated.Stored, updated.Expires = now, now.Add(CacheTTL(url))
+		c.cache.Set(url, &updated)
+		return entry.Data, nil
+	}
+	c.cache.Set(url, &CacheEntry{
+		Data:         resp.data,
+		ETag:         resp.header.Get("ETag"),
+		LastModified: resp.header.Get("Last-Modified"),
+		Stored:       now,
+		Expires:      now.Add(CacheTTL(url)),
+	})
+	return resp.data, nil
+}
+
+// Cached проверяет наличие актуального ответа в кэше без чтения записи кэша на диске.
+func (c *apiClient) Cached(url string) bool {
+	return c.cache != nil && cacheFresh(c.cache, url)
+}
+
+// Counters возвращает счетчики обращений к кэшу.
+func (c *apiClient) Counters() CacheCounters {
+	return CacheCounters{
+		Hits:        atomic.LoadInt64(&c.counters.Hits),
+		Misses:      atomic.LoadInt64(&c.counters.Misses),
+		Revalidated: atomic.LoadInt64(&c.counters.Revalidated),
+	}
+}
+
+type apiResponse struct {
+	data        []byte
+	header      http.Header
+	notModified bool
+}
+
+// Загрузка ресурса с повтором запроса при временных ошибках. Для записи кэша, допускающей
+// повторную проверку, выполняется условный запрос.
+func (c *apiClient) load(url string, entry *CacheEntry) (*apiResponse, error) {
+	header := http.Header{}
+	if entry != nil && entry.Revalidatable() {
+		if entry.ETag != "" {
+			header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := c.do(http.MethodGet, url, header)
+		if err == nil || attempt == MaxRetries {
+			return resp, err
+		}
+		delay := c.backoff(attempt)
+		if statusErr, ok := err.(*StatusError); ok {
+			if !statusErr.Temporary() {
+				return nil, err
+			}
+			if statusErr.RetryAfter > delay {
+				delay = statusErr.RetryAfter
+			}
+			if statusErr.StatusCode == http.StatusTooManyRequests {
+				c.limiter.Pause(delay)
+			}
+		}
+		c.log.WithField("attempt", attempt+1).WithField("delay", delay).Warn(err)
+		time.Sleep(delay)
+	}
+}
+
+// Head выполняет запрос "HEAD" для получения заголовков ответа.
+func (c *apiClient) Head(url string) (http.Header, error) {
+	c.limiter.Wait()
+	resp, err := c.send(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	c.limiter.Update(resp.Header)
+	return resp.Header, nil
+}
+
+func (c *apiClient) do(method, url string, header http.Header) (*apiResponse, error) {
+	c.limiter.Wait()
+	c.log.Debug(url)
+	resp, err := c.send(method, url, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	c.limiter.Update(resp.Header)
+
+	if resp.StatusCode == http.StatusNotModified {
+		return &apiResponse{header: resp.Header, notModified: true}, nil
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		statusErr := &StatusError{
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			RetryAfter: retryAfter(resp.Header),
+		}
+		var body struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &body) == nil {
+			statusErr.Message = body.Message
+		}
+		return nil, statusErr
+	}
+	return &apiResponse{data: data, header: resp.Header}, nil
+}
+
+func (c *apiClient) send(method, url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.headers {
+		req.Header.Add(k, v)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return c.client.Do(req)
+}
+
+// Экспоненциальная задержка со случайной составляющей в диапазоне [d/2, d).
+func (c *apiClient) backoff(attempt int) time.Duration {
+	d := c.retryDelay << uint(attempt)
+	if d > MaxRetryDelay || d <= 0 {
+		d = MaxRetryDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Значение заголовка Retry-After в секундах.
+func retryAfter(header http.Header) time.Duration {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
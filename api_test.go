@@ -0,0 +1,66 @@
+package discogs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIClientRetry(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set(RateHeaderKey, "60")
+		w.Header().Set(RateUsedHeaderKey, "10")
+		w.Header().Set(RateRemainingHeaderKey, "50")
+		switch {
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Release not found."}`))
+		case requests == 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case requests == 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Write([]byte(`{"id": 1}`))
+		}
+	}))
+	defer ts.Close()
+
+	c := newAPIClient(nil, log.New())
+	c.retryDelay = time.Millisecond
+	c.limiter.setLimit(6000)
+
+	var out struct {
+		ID int `json:"id"`
+	}
+	require.NoError(t, c.DecodeJSON(ts.URL+"/releases/1", &out))
+	assert.Equal(t, 1, out.ID)
+	assert.Equal(t, 3, requests)
+
+	limit, used, remaining := c.limiter.Budget()
+	assert.Equal(t, []int{60, 10, 50}, []int{limit, used, remaining})
+	assert.Equal(t, time.Second, c.limiter.Interval())
+
+	c.limiter.setLimit(6000)
+	err := c.DecodeJSON(ts.URL+"/missing", &out)
+	require.IsType(t, &StatusError{}, err)
+	assert.Equal(t, http.StatusNotFound, err.(*StatusError).StatusCode)
+	assert.Equal(t, "Release not found.", err.(*StatusError).Message)
+	assert.Equal(t, 4, requests)
+}
+
+func TestRateLimiterPause(t *testing.T) {
+	rl := newRateLimiter(6000, log.New())
+	header := http.Header{}
+	header.Set(RateHeaderKey, "6000")
+	header.Set(RateUsedHeaderKey, "6000")
+	header.Set(RateRemainingHeaderKey, "0")
+	rl.Update(header)
+	assert.True(t, rl.next.After(time.Now().Add(RateWindow-time.Second)))
+}
@@ -0,0 +1,79 @@
+package discogs
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// Backend - источник данных Discogs. Методы возвращают документы в формате JSON ответов
+// Discogs API (https://www.discogs.com/developers), преобразование которых в метаданные
+// выполняется сервисом независимо от источника.
+// Параметры `params` соответствуют параметрам запросов Discogs API: поиска, фильтра
+// списка версий мастер-релиза и страницы списка.
+type Backend interface {
+	Search(params url.Values) ([]byte, error)
+	Release(id string) ([]byte, error)
+	Master(id string) ([]byte, error)
+	Versions(masterID string, params url.Values) ([]byte, error)
+	Artist(id string) ([]byte, error)
+	Label(id string) ([]byte, error)
+	LabelReleases(labelID string, params url.Values) ([]byte, error)
+}
+
+// httpBackend получает данные от Discogs API (или совместимого с ним сервера).
+type httpBackend struct {
+	api     *apiClient
+	baseURL string
+}
+
+func (b *httpBackend) Search(params url.Values) ([]byte, error) {
+	return b.api.Load(b.url("database/search", params))
+}
+
+func (b *httpBackend) Release(id string) ([]byte, error) {
+	return b.api.Load(b.url("releases/"+id, nil))
+}
+
+func (b *httpBackend) Master(id string) ([]byte, error) {
+	return b.api.Load(b.url("masters/"+id, nil))
+}
+
+func (b *httpBackend) Versions(masterID string, params url.Values) ([]byte, error) {
+	return b.api.Load(b.url("masters/"+masterID+"/versions", params))
+}
+
+func (b *httpBackend) Artist(id string) ([]byte, error) {
+	return b.api.Load(b.url("artists/"+id, nil))
+}
+
+func (b *httpBackend) Label(id string) ([]byte, error) {
+	return b.api.Load(b.url("labels/"+id, nil))
+}
+
+func (b *httpBackend) LabelReleases(labelID string, params url.Values) ([]byte, error) {
+	return b.api.Load(b.url("labels/"+labelID+"/releases", params))
+}
+
+func (b *httpBackend) url(path string, params url.Values) string {
+	if len(params) == 0 {
+		return b.baseURL + path
+	}
+	return b.baseURL + path + "?" + params.Encode()
+}
+
+// Базовый URL всегда завершается символом "/".
+func normalizeBaseURL(baseURL string) string {
+	if !strings.HasSuffix(baseURL, "/") {
+		return baseURL + "/"
+	}
+	return baseURL
+}
+
+// decodeDoc декодирует документ, полученный от источника данных.
+func decodeDoc(data []byte, err error, out interface{}) error {
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,85 @@
+package discogs
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+// Записанный ответ поиска релиза (см. TestGoldenSearch).
+const searchFixture = "replay/database_search_artist=Pink+Floyd&title=The+Dark+Side+Of+The+Moon&type=release"
+
+// Источник данных, возвращающий документы из каталога testdata.
+type testdataBackend struct {
+	requests []string
+}
+
+func (b *testdataBackend) load(name string) ([]byte, error) {
+	b.requests = append(b.requests, name)
+	return ioutil.ReadFile(filepath.Join("testdata", name+".json"))
+}
+
+func (b *testdataBackend) Search(params url.Values) ([]byte, error) { return b.load(searchFixture) }
+func (b *testdataBackend) Release(id string) ([]byte, error)        { return b.load("release") }
+func (b *testdataBackend) Master(id string) ([]byte, error)         { return b.load("master") }
+func (b *testdataBackend) Artist(id string) ([]byte, error)         { return b.load("artist") }
+func (b *testdataBackend) Label(id string) ([]byte, error)          { return b.load("label") }
+
+func (b *testdataBackend) Versions(masterID string, params url.Values) ([]byte, error) {
+	return b.load("versions")
+}
+
+func (b *testdataBackend) LabelReleases(labelID string, params url.Values) ([]byte, error) {
+	return b.load("label_releases")
+}
+
+func TestWithBackend(t *testing.T) {
+	backend := &testdataBackend{}
+	d := New("", "", WithBackend(backend))
+
+	r := md.NewRelease()
+	require.NoError(t, d.releaseByID("4139588", r))
+	assert.Equal(t, "The Dark Side Of The Moon", r.Title)
+	assert.Equal(t, "10362", r.Original.IDs[md.DiscogsMasterID])
+	assert.Equal(t, []string{"release", "master"}, backend.requests)
+
+	results, _, err := d.searchPages(url.Values{"type": {"release"}}, 1)
+	require.NoError(t, err)
+	assert.Len(t, results, 3)
+}
+
+func TestWithBaseURL(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/api/releases/4139588":
+			http.ServeFile(w, r, filepath.Join("testdata", "release.json"))
+		case "/api/masters/10362":
+			http.ServeFile(w, r, filepath.Join("testdata", "master.json"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	d := New("", "", WithBaseURL(ts.URL+"/api"), WithHTTPClient(ts.Client()))
+	d.api.limiter.setLimit(0)
+
+	r := md.NewRelease()
+	require.NoError(t, d.releaseByID("4139588", r))
+	assert.Equal(t, "The Dark Side Of The Moon", r.Title)
+	assert.Equal(t, []string{"/api/releases/4139588", "/api/masters/10362"}, paths)
+
+	_, err := d.backend.Versions("10362", url.Values{"page": {"2"}})
+	require.IsType(t, &StatusError{}, err)
+	assert.Equal(t, "/api/masters/10362/versions?page=2", paths[2])
+}
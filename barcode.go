@@ -0,0 +1,74 @@
+package discogs
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeBarcode приводит штрих-код UPC/EAN к единому виду для сравнения: удаляет пробелы
+// и разделители, проверяет контрольную цифру и приводит UPC-A (12 цифр) и GTIN-14 с ведущим
+// нулем к GTIN-13 (EAN-13), так что разные записи одного кода совпадают. Для кодов EAN-8 и
+// UPC-A без контрольной цифры (7 и 11 цифр) она вычисляется и добавляется. Код с неверной
+// контрольной цифрой считается некорректным. Второй результат сообщает о корректности штрих-кода.
+func NormalizeBarcode(barcode string) (string, bool) {
+	digits, ok := barcodeDigits(barcode)
+	if !ok {
+		return "", false
+	}
+	switch {
+	case len(digits) == 12:
+		return "0" + digits, true
+	case len(digits) == 14 && digits[0] == '0':
+		return digits[1:], true
+	}
+	return digits, true
+}
+
+// barcodeDigits возвращает цифры штрих-кода в исходной форме с проверенной (или добавленной
+// для 7 и 11 цифр) контрольной цифрой.
+func barcodeDigits(barcode string) (string, bool) {
+	var sb strings.Builder
+	for _, ch := range barcode {
+		switch {
+		case ch >= '0' && ch <= '9':
+			sb.WriteRune(ch)
+		case unicode.IsSpace(ch) || ch == '-' || ch == '.':
+		default:
+			return "", false
+		}
+	}
+	digits := sb.String()
+	switch len(digits) {
+	case 8, 12, 13, 14:
+		if checkDigit(digits[:len(digits)-1]) == digits[len(digits)-1] {
+			return digits, true
+		}
+	case 7, 11:
+		return digits + string(checkDigit(digits)), true
+	}
+	return "", false
+}
+
+// Контрольная цифра GTIN: веса 3 и 1 чередуются справа налево.
+func checkDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		n := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 0 {
+			n *= 3
+		}
+		sum += n
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// Номер в каталоге сравнивается без учета регистра, пробелов и разделителей.
+func normalizeCatno(catno string) string {
+	var sb strings.Builder
+	for _, ch := range strings.ToUpper(catno) {
+		if unicode.IsLetter(ch) || unicode.IsDigit(ch) {
+			sb.WriteRune(ch)
+		}
+	}
+	return sb.String()
+}
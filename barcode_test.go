@@ -0,0 +1,49 @@
+package discogs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+func TestNormalizeBarcode(t *testing.T) {
+	for _, tc := range []struct {
+		in, out string
+		ok      bool
+	}{
+		{"5 099902 987613", "5099902987613", true},  // EAN-13
+		{"0 77774-6001-2 5", "0077774600125", true}, // UPC-A
+		{"0077774600125", "0077774600125", true},    // UPC-A в форме EAN-13
+		{"00077774600125", "0077774600125", true},   // UPC-A в форме GTIN-14
+		{"07777460012", "0077774600125", true},      // UPC-A без контрольной цифры
+		{"96385074", "96385074", true},              // EAN-8
+		{"509990298761", "", false},                 // неверная контрольная цифра UPC-A
+		{"5099902987614", "", false},
+		{"SHVL 804", "", false},
+		{"", "", false},
+	} {
+		out, ok := NormalizeBarcode(tc.in)
+		assert.Equal(t, tc.ok, ok, tc.in)
+		assert.Equal(t, tc.out, out, tc.in)
+	}
+}
+
+func TestSearchResultIdentifiers(t *testing.T) {
+	result := &searchResult{
+		Barcode: []string{"SHVL 804 A-9", "5 099902 987613", "0 77774-6001-2 5"},
+		Label:   []string{"Harvest", "EMI"},
+		CatNo:   "SHVL 804",
+	}
+	assert.True(t, result.HasBarcode("5099902987613"))
+	assert.True(t, result.HasBarcode("0077774600125"))
+	assert.False(t, result.HasBarcode("0077774600224"))
+	assert.True(t, result.HasLabel(md.NewLabel("", "shvl-804"), MinSearchFullResult))
+	assert.True(t, result.HasLabel(md.NewLabel("EMI", "SHVL804"), MinSearchFullResult))
+	assert.False(t, result.HasLabel(md.NewLabel("Capitol", "SHVL 804"), MinSearchFullResult))
+	assert.False(t, result.HasLabel(md.NewLabel("Harvest", "SMAS-11163"), MinSearchFullResult))
+	// порог сходства наименований лейблов задается параметрами поиска
+	assert.True(t, result.HasLabel(md.NewLabel("Harvest Records", "SHVL 804"), MinSearchFullResult))
+	assert.False(t, result.HasLabel(md.NewLabel("Harvest Records", "SHVL 804"), .99))
+}
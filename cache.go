@@ -0,0 +1,411 @@
+package discogs
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheTTLs задает время жизни ответов Discogs API в кэше в зависимости от типа сущности
+// (элемента пути URL). Для путей, отсутствующих в словаре, используется DefaultCacheTTL.
+var CacheTTLs = map[string]time.Duration{
+	"releases": 7 * 24 * time.Hour,
+	"masters":  7 * 24 * time.Hour,
+	"artists":  24 * time.Hour,
+	"labels":   24 * time.Hour,
+	"database": time.Hour,
+}
+
+// DefaultCacheTTL - время жизни в кэше ответов для путей, отсутствующих в CacheTTLs.
+const DefaultCacheTTL = time.Hour
+
+// CacheEntry хранит ответ Discogs API и сведения для его повторной проверки на сервере.
+type CacheEntry struct {
+	Data         []byte    `json:"data"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Stored       time.Time `json:"stored"`
+	Expires      time.Time `json:"expires"`
+}
+
+// Fresh проверяет, не истекло ли время жизни записи.
+func (e *CacheEntry) Fresh() bool {
+	return time.Now().Before(e.Expires)
+}
+
+// Revalidatable проверяет возможность условного запроса для обновления записи.
+func (e *CacheEntry) Revalidatable() bool {
+	return e.ETag != "" || e.LastModified != ""
+}
+
+// CacheStats описывает состояние кэша.
+type CacheStats struct {
+	Entries int   `json:"entries"`
+	Size    int64 `json:"size"`
+}
+
+// Cache - хранилище ответов Discogs API, ключом которого является URL запроса.
+// Реализации должны допускать одновременное использование из нескольких go-процедур.
+type Cache interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+	Keys() []string
+	Stats() CacheStats
+}
+
+// freshCache - кэш, проверяющий наличие актуальной записи без ее чтения.
+type freshCache interface {
+	fresh(key string) bool
+}
+
+// Проверка наличия актуальной записи: без чтения записи, если кэш это допускает.
+func cacheFresh(c Cache, key string) bool {
+	if fc, ok := c.(freshCache); ok {
+		return fc.fresh(key)
+	}
+	entry, ok := c.Get(key)
+	return ok && entry.Fresh()
+}
+
+// CacheTTL определяет время жизни ответа по URL запроса.
+func CacheTTL(rawurl string) time.Duration {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return DefaultCacheTTL
+	}
+	// базовый URL сервера может содержать дополнительный путь
+	for _, entity := range strings.Split(strings.Trim(u.Path, "/"), "/") {
+		if ttl, ok := CacheTTLs[entity]; ok {
+			return ttl
+		}
+	}
+	return DefaultCacheTTL
+}
+
+// PurgeCache удаляет из кэша записи, ключ которых начинается с `prefix`, и возвращает их
+// количество. Пустой префикс очищает кэш полностью.
+func PurgeCache(c Cache, prefix string) int {
+	n := 0
+	for _, key := range c.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			c.Delete(key)
+			n++
+		}
+	}
+	return n
+}
+
+type memoryItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// memoryCache - LRU кэш в оперативной памяти с ограничением количества записей и их
+// суммарного размера.
+type memoryCache struct {
+	mu         sync.Mutex
+	items      map[string]*list.Element
+	lru        *list.List
+	size       int64
+	maxEntries int
+	maxSize    int64
+}
+
+// NewMemoryCache создает LRU кэш в оперативной памяти.
+// Нулевые значения ограничений означают их отсутствие.
+func NewMemoryCache(maxEntries int, maxSize int64) Cache {
+	return &memoryCache{
+		items:      map[string]*list.Element{},
+		lru:        list.New(),
+		maxEntries: maxEntries,
+		maxSize:    maxSize,
+	}
+}
+
+func (mc *memoryCache) Get(key string) (*CacheEntry, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if el, ok := mc.items[key]; ok {
+		mc.lru.MoveToFront(el)
+		return el.Value.(*memoryItem).entry, true
+	}
+	return nil, false
+}
+
+func (mc *memoryCache) fresh(key string) bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	el, ok := mc.items[key]
+	return ok && el.Value.(*memoryItem).entry.Fresh()
+}
+
+func (mc *memoryCache) Set(key string, entry *CacheEntry) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if el, ok := mc.items[key]; ok {
+		mc.remove(el)
+	}
+	mc.items[key] = mc.lru.PushFront(&memoryItem{key: key, entry: entry})
+	mc.size += int64(len(entry.Data))
+	for mc.lru.Len() > 1 &&
+		(mc.maxEntries > 0 && mc.lru.Len() > mc.maxEntries || mc.maxSize > 0 && mc.size > mc.maxSize) {
+		mc.remove(mc.lru.Back())
+	}
+}
+
+func (mc *memoryCache) Delete(key string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if el, ok := mc.items[key]; ok {
+		mc.remove(el)
+	}
+}
+
+func (mc *memoryCache) Keys() []string {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	keys := make([]string, 0, len(mc.items))
+	for key := range mc.items {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (mc *memoryCache) Stats() CacheStats {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return CacheStats{Entries: mc.lru.Len(), Size: mc.size}
+}
+
+func (mc *memoryCache) remove(el *list.Element) {
+	item := mc.lru.Remove(el).(*memoryItem)
+	delete(mc.items, item.key)
+	mc.size -= int64(len(item.entry.Data))
+}
+
+type diskItem struct {
+	Key   string      `json:"key"`
+	Entry *CacheEntry `json:"entry"`
+}
+
+type diskIndexItem struct {
+	key     string
+	size    int64
+	stored  time.Time
+	expires time.Time
+}
+
+// diskCache хранит каждую запись в отдельном файле каталога. При превышении суммарного
+// размера записей удаляются записи, сохраненные раньше остальных.
+type diskCache struct {
+	mu      sync.Mutex
+	dir     string
+	index   map[string]*diskIndexItem
+	size    int64
+	maxSize int64
+}
+
+// NewDiskCache создает (или открывает существующий) кэш в каталоге `dir`.
+// Нулевое значение `maxSize` означает отсутствие ограничения размера.
+func NewDiskCache(dir string, maxSize int64) (Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	dc := &diskCache{dir: dir, index: map[string]*diskIndexItem{}, maxSize: maxSize}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range files {
+		if fi.IsDir() || filepath.Ext(fi.Name()) != ".json" {
+			continue
+		}
+		item, err := dc.read(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			continue
+		}
+		dc.index[item.Key] = &diskIndexItem{
+			key: item.Key, size: fi.Size(), stored: item.Entry.Stored, expires: item.Entry.Expires}
+		dc.size += fi.Size()
+	}
+	return dc, nil
+}
+
+func (dc *diskCache) Get(key string) (*CacheEntry, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if _, ok := dc.index[key]; !ok {
+		return nil, false
+	}
+	item, err := dc.read(dc.path(key))
+	if err != nil || item.Key != key {
+		dc.remove(key)
+		return nil, false
+	}
+	return item.Entry, true
+}
+
+// Актуальность записи определяется по индексу без чтения файла.
+func (dc *diskCache) fresh(key string) bool {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	item, ok := dc.index[key]
+	return ok && time.Now().Before(item.expires)
+}
+
+func (dc *diskCache) Set(key string, entry *CacheEntry) {
+	data, err := json.Marshal(diskItem{Key: key, Entry: entry})
+	if err != nil {
+		return
+	}
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.remove(key)
+	if err := ioutil.WriteFile(dc.path(key), data, 0644); err != nil {
+		return
+	}
+	dc.index[key] = &diskIndexItem{
+		key: key, size: int64(len(data)), stored: entry.Stored, expires: entry.Expires}
+	dc.size += int64(len(data))
+	if dc.maxSize > 0 && dc.size > dc.maxSize {
+		dc.evict(key)
+	}
+}
+
+func (dc *diskCache) Delete(key string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.remove(key)
+}
+
+func (dc *diskCache) Keys() []string {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	keys := make([]string, 0, len(dc.index))
+	for key := range dc.index {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (dc *diskCache) Stats() CacheStats {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return CacheStats{Entries: len(dc.index), Size: dc.size}
+}
+
+func (dc *diskCache) path(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(dc.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (dc *diskCache) read(path string) (*diskItem, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var item diskItem
+	if err = json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+	if item.Entry == nil {
+		return nil, os.ErrNotExist
+	}
+	return &item, nil
+}
+
+func (dc *diskCache) remove(key string) {
+	if item, ok := dc.index[key]; ok {
+		os.Remove(dc.path(key))
+		dc.size -= item.size
+		delete(dc.index, key)
+	}
+}
+
+// Удаление самых старых записей до соответствия ограничению размера.
+// Только что сохраненная запись `keep` не удаляется.
+func (dc *diskCache) evict(keep string) {
+	items := make([]*diskIndexItem, 0, len(dc.index))
+	for _, item := range dc.index {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].stored.Before(items[j].stored) })
+	for _, item := range items {
+		if dc.size <= dc.maxSize {
+			break
+		}
+		if item.key != keep {
+			dc.remove(item.key)
+		}
+	}
+}
+
+// layeredCache объединяет несколько кэшей: чтение выполняется по порядку уровней с
+// переносом найденной записи на верхние уровни, запись - во все уровни.
+type layeredCache struct {
+	layers []Cache
+}
+
+// NewLayeredCache создает многоуровневый кэш, например, LRU кэш в памяти поверх кэша на диске.
+func NewLayeredCache(layers ...Cache) Cache {
+	return &layeredCache{layers: layers}
+}
+
+func (lc *layeredCache) Get(key string) (*CacheEntry, bool) {
+	for i, layer := range lc.layers {
+		if entry, ok := layer.Get(key); ok {
+			for j := 0; j < i; j++ {
+				lc.layers[j].Set(key, entry)
+			}
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+func (lc *layeredCache) fresh(key string) bool {
+	for _, layer := range lc.layers {
+		if cacheFresh(layer, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (lc *layeredCache) Set(key string, entry *CacheEntry) {
+	for _, layer := range lc.layers {
+		layer.Set(key, entry)
+	}
+}
+
+func (lc *layeredCache) Delete(key string) {
+	for _, layer := range lc.layers {
+		layer.Delete(key)
+	}
+}
+
+// Keys возвращает ключи нижнего (самого полного) уровня.
+func (lc *layeredCache) Keys() []string {
+	if len(lc.layers) == 0 {
+		return nil
+	}
+	return lc.layers[len(lc.layers)-1].Keys()
+}
+
+// Stats возвращает состояние нижнего (самого полного) уровня.
+func (lc *layeredCache) Stats() CacheStats {
+	if len(lc.layers) == 0 {
+		return CacheStats{}
+	}
+	return lc.layers[len(lc.layers)-1].Stats()
+}
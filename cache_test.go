@@ -0,0 +1,112 @@
+package discogs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCacheEntry(data string, ttl time.Duration) *CacheEntry {
+	now := time.Now()
+	return &CacheEntry{Data: []byte(data), Stored: now, Expires: now.Add(ttl)}
+}
+
+func TestMemoryCache(t *testing.T) {
+	c := NewMemoryCache(2, 0)
+	c.Set("a", newCacheEntry("1", time.Hour))
+	c.Set("b", newCacheEntry("2", time.Hour))
+	_, ok := c.Get("a") // "b" становится самой старой записью
+	require.True(t, ok)
+	c.Set("c", newCacheEntry("3", time.Hour))
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, CacheStats{Entries: 2, Size: 2}, c.Stats())
+
+	c = NewMemoryCache(0, 5)
+	c.Set("a", newCacheEntry("123", time.Hour))
+	c.Set("b", newCacheEntry("456", time.Hour))
+	assert.Equal(t, []string{"b"}, c.Keys())
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskCache(dir, 0)
+	require.NoError(t, err)
+	entry := newCacheEntry(`{"id": 1}`, time.Hour)
+	entry.ETag = `"abc"`
+	c.Set(BaseURL+"releases/1", entry)
+	c.Set(BaseURL+"masters/2", newCacheEntry(`{"id": 2}`, time.Hour))
+
+	c, err = NewDiskCache(dir, 0)
+	require.NoError(t, err)
+	cached, ok := c.Get(BaseURL + "releases/1")
+	require.True(t, ok)
+	assert.Equal(t, `{"id": 1}`, string(cached.Data))
+	assert.Equal(t, `"abc"`, cached.ETag)
+	assert.Equal(t, 2, c.Stats().Entries)
+
+	// актуальность записей проверяется по индексу кэша
+	c.Set(BaseURL+"releases/4", newCacheEntry(`{"id": 4}`, -time.Second))
+	assert.True(t, cacheFresh(c, BaseURL+"masters/2"))
+	assert.False(t, cacheFresh(c, BaseURL+"releases/4"))
+	assert.True(t, cacheFresh(NewLayeredCache(NewMemoryCache(0, 0), c), BaseURL+"masters/2"))
+	c.Delete(BaseURL + "releases/4")
+
+	assert.Equal(t, 1, PurgeCache(c, BaseURL+"masters/"))
+	assert.Equal(t, []string{BaseURL + "releases/1"}, c.Keys())
+
+	size := c.Stats().Size
+	c, err = NewDiskCache(dir, size+size/2)
+	require.NoError(t, err)
+	c.Set(BaseURL+"releases/3", newCacheEntry(`{"id": 3}`, time.Hour))
+	assert.Equal(t, []string{BaseURL + "releases/3"}, c.Keys())
+}
+
+func TestCacheTTL(t *testing.T) {
+	assert.Equal(t, CacheTTLs["releases"], CacheTTL(BaseURL+"releases/1"))
+	assert.Equal(t, CacheTTLs["database"], CacheTTL(BaseURL+"database/search?q=abc"))
+	assert.Equal(t, DefaultCacheTTL, CacheTTL(BaseURL+"users/abc"))
+}
+
+func TestAPIClientCache(t *testing.T) {
+	var requests, notModified int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"id": 1}`))
+	}))
+	defer ts.Close()
+
+	c := newAPIClient(nil, log.New())
+	c.limiter.setLimit(6000)
+	c.cache = NewMemoryCache(0, 0)
+	url := ts.URL + "/releases/1"
+
+	for i := 0; i < 2; i++ {
+		data, err := c.Load(url)
+		require.NoError(t, err)
+		assert.Equal(t, `{"id": 1}`, string(data))
+	}
+	assert.Equal(t, 1, requests)
+	assert.True(t, c.Cached(url))
+
+	entry, _ := c.cache.Get(url)
+	entry.Expires = time.Now().Add(-time.Second)
+	data, err := c.Load(url)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id": 1}`, string(data))
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 1, notModified)
+	assert.True(t, c.Cached(url))
+	assert.Equal(t, CacheCounters{Hits: 1, Misses: 2, Revalidated: 1}, c.Counters())
+}
@@ -2,26 +2,312 @@ package discogs
 
 import (
 	"encoding/json"
-	"errors"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/gofrs/uuid"
 
 	md "github.com/ytsiuryn/ds-audiomd"
-	srv "github.com/ytsiuryn/ds-microservice"
 )
 
 // AudioOnlineRequest описывает структуру запроса к микросервису.
 type AudioOnlineRequest struct {
 	Cmd     string      `json:"cmd"`
 	Release *md.Release `json:"release"`
-	// Actor
-	// *md.Publishing
+	Actor   *Actor      `json:"actor,omitempty"`
+	Label   *md.Label   `json:"label,omitempty"`
+	// Pagination задает страницу дополнительного списка (например, релизов лейбла).
+	Pagination *Pagination `json:"pagination,omitempty"`
+	// Versions задает фильтр списка версий мастер-релиза.
+	Versions *VersionFilter `json:"versions,omitempty"`
+	// Cache задает параметры административной команды "cache".
+	Cache *CacheRequest `json:"cache,omitempty"`
+	// Stream включает для команды "release" выдачу промежуточных ответов (см. Stage).
+	Stream bool `json:"stream,omitempty"`
+	// Search задает параметры поиска команды "release" вместо параметров сервиса.
+	Search *SearchOptions `json:"search,omitempty"`
+	// Explain добавляет к ответу команды "release" описание оценок предложений.
+	Explain bool `json:"explain,omitempty"`
+}
+
+// CacheRequest описывает действие административной команды "cache":
+// "stats" (по умолчанию) - состояние кэша, "inspect" - список записей,
+// "purge" - удаление записей. Prefix ограничивает записи, ключ (URL) которых
+// начинается с указанной строки.
+type CacheRequest struct {
+	Action string `json:"action,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// VersionFilter описывает условия отбора версий мастер-релиза.
+// Пустые поля не участвуют в отборе.
+type VersionFilter struct {
+	Format  string `json:"format,omitempty"`
+	Country string `json:"country,omitempty"`
+	Year    int    `json:"year,omitempty"`
+}
+
+// SearchOptions описывает параметры поиска релиза. Незаданные (нулевые) поля заменяются
+// параметрами сервиса (см. WithSearchOptions).
+type SearchOptions struct {
+	// Минимальная оценка сходства кандидата по данным результатов поиска
+	// (по умолчанию MinSearchShortResult).
+	MinShortResult *float64 `json:"min_short_result,omitempty"`
+	// Минимальная оценка сходства кандидата по полным данным релиза
+	// (по умолчанию MinSearchFullResult).
+	MinFullResult *float64 `json:"min_full_result,omitempty"`
+	// Количество кандидатов, загружаемых для полной оценки (по умолчанию MaxPreSuggestions).
+	MaxCandidates int `json:"max_candidates,omitempty"`
+	// Количество кандидатов, при котором следующие стратегии поиска не применяются
+	// (по умолчанию MinPreSuggestions).
+	MinCandidates *int `json:"min_candidates,omitempty"`
+	// Количество предложений в ответе (по умолчанию MaxSuggestions).
+	MaxSuggestions int `json:"max_suggestions,omitempty"`
+	// Количество просматриваемых страниц результатов поиска (по умолчанию 1).
+	SearchPages int `json:"search_pages,omitempty"`
+	// Загрузка мастер-релизов кандидатов и исследование их версий (по умолчанию true).
+	FetchMasters *bool `json:"fetch_masters,omitempty"`
+}
+
+// Actor описывает исполнителя в запросе к микросервису: ID в БД Discogs или имя.
+type Actor struct {
+	Name md.ActorName `json:"name,omitempty"`
+	IDs  md.ActorIDs  `json:"ids,omitempty"`
 }
 
 // AudioOnlineResponse описывает структуру ответа микросервиса.
 type AudioOnlineResponse struct {
-	SuggestionSet *md.SuggestionSet  `json:"suggestion_set,omitempty"`
-	Error         *srv.ErrorResponse `json:"error,omitempty"`
+	SuggestionSet *md.SuggestionSet   `json:"suggestion_set,omitempty"`
+	Artists       []*ArtistSuggestion `json:"artists,omitempty"`
+	Labels        []*LabelSuggestion  `json:"labels,omitempty"`
+	Master        *MasterProfile      `json:"master,omitempty"`
+	Cache         *CacheInfo          `json:"cache,omitempty"`
+	Info          *ServiceInfo        `json:"info,omitempty"`
+	Error         *ErrorResponse      `json:"error,omitempty"`
+	Stage         Stage               `json:"stage,omitempty"`
+	// Explanations содержит описания оценок предложений по их ID в БД Discogs
+	// (только для запросов с флагом Explain).
+	Explanations map[string]*Explanation `json:"explanations,omitempty"`
+}
+
+// Stage - этап выдачи ответа на команду "release" в потоковом режиме. Промежуточные ответы
+// публикуются в очередь ответов с тем же CorrelationId, что и окончательный.
+type Stage string
+
+// Этапы потоковой выдачи ответа.
+const (
+	// Предварительные кандидаты по данным страницы результатов поиска.
+	StagePreliminary Stage = "preliminary"
+	// Кандидат, оцененный по полным данным релиза.
+	StageSuggestion Stage = "suggestion"
+	// Окончательный ответ (в т.ч. с ошибкой).
+	StageDone Stage = "done"
+)
+
+// Intermediate сообщает, что ответ на этом этапе не является окончательным.
+func (s Stage) Intermediate() bool {
+	return s == StagePreliminary || s == StageSuggestion
+}
+
+// Pagination описывает страницу списка в запросе и ответе микросервиса.
+// Поля Pages и Items заполняются только в ответе.
+type Pagination struct {
+	Page    int `json:"page,omitempty"`
+	Pages   int `json:"pages,omitempty"`
+	PerPage int `json:"per_page,omitempty"`
+	Items   int `json:"items,omitempty"`
+}
+
+// ArtistLink описывает ссылку на связанного исполнителя (псевдоним, участника группы и т.д.).
+type ArtistLink struct {
+	Name   md.ActorName `json:"name"`
+	Active bool         `json:"active,omitempty"`
+	IDs    md.ActorIDs  `json:"ids,omitempty"`
+}
+
+// ArtistProfile содержит полные сведения об исполнителе из БД Discogs.
+type ArtistProfile struct {
+	Name           md.ActorName         `json:"name"`
+	RealName       string               `json:"real_name,omitempty"`
+	Profile        string               `json:"profile,omitempty"`
+	Aliases        []*ArtistLink        `json:"aliases,omitempty"`
+	NameVariations []string             `json:"name_variations,omitempty"`
+	Members        []*ArtistLink        `json:"members,omitempty"`
+	Groups         []*ArtistLink        `json:"groups,omitempty"`
+	URLs           []string             `json:"urls,omitempty"`
+	Pictures       []*md.PictureInAudio `json:"pictures,omitempty"`
+	IDs            md.ActorIDs          `json:"ids,omitempty"`
+}
+
+// ArtistSuggestion хранит единичный результат поиска исполнителя.
+type ArtistSuggestion struct {
+	Artist           *ArtistProfile `json:"artist"`
+	ServiceName      string         `json:"service"`
+	SourceSimilarity float64        `json:"score"`
+}
+
+// LabelLink описывает ссылку на связанный лейбл (родительский или дочерний).
+type LabelLink struct {
+	Name string      `json:"name"`
+	IDs  md.LabelIDs `json:"ids,omitempty"`
+}
+
+// LabelRelease описывает релиз из списка релизов лейбла.
+type LabelRelease struct {
+	Title  string        `json:"title"`
+	Artist string        `json:"artist,omitempty"`
+	Format string        `json:"format,omitempty"`
+	Catno  string        `json:"catno,omitempty"`
+	Status string        `json:"status,omitempty"`
+	Year   int           `json:"year,omitempty"`
+	IDs    md.ReleaseIDs `json:"ids,omitempty"`
+}
+
+// LabelProfile содержит полные сведения о лейбле из БД Discogs.
+type LabelProfile struct {
+	Name        string               `json:"name"`
+	Profile     string               `json:"profile,omitempty"`
+	ContactInfo string               `json:"contact_info,omitempty"`
+	ParentLabel *LabelLink           `json:"parent_label,omitempty"`
+	Sublabels   []*LabelLink         `json:"sublabels,omitempty"`
+	URLs        []string             `json:"urls,omitempty"`
+	Pictures    []*md.PictureInAudio `json:"pictures,omitempty"`
+	IDs         md.LabelIDs          `json:"ids,omitempty"`
+	Releases    []*LabelRelease      `json:"releases,omitempty"`
+	Pagination  *Pagination          `json:"pagination,omitempty"`
+}
+
+// CompanyKeyPrefix - префикс ключей несистематизированных данных релиза (md.Release.Unprocessed),
+// под которыми хранятся сведения о компаниях, участвовавших в производстве релиза. Каждой
+// компании соответствует один ключ: CompanyKeyPrefix + ID лейбла компании в БД Discogs (при его
+// отсутствии - наименование в snake_case), значение ключа - Company в формате JSON.
+// Например: "company:253617" -> {"name":"The Gramophone Co. Ltd.","roles":["phonographic_copyright",
+// "record_company"],"ids":{"discogs_label_id":"253617"}}.
+const CompanyKeyPrefix = "company:"
+
+// Company описывает компанию, участвовавшую в производстве релиза (правообладателя, завод-изготовитель,
+// студию звукозаписи и т.д.). Роли компании приводятся к snake_case: "Phonographic Copyright (p)" ->
+// "phonographic_copyright", "Recorded At" -> "recorded_at".
+type Company struct {
+	Name   string      `json:"name"`
+	Roles  []string    `json:"roles"`
+	Catnos []string    `json:"catnos,omitempty"`
+	IDs    md.LabelIDs `json:"ids,omitempty"`
+}
+
+// Companies возвращает сведения о компаниях из несистематизированных данных релиза,
+// упорядоченные по наименованию. Некорректные значения пропускаются.
+func Companies(r *md.Release) []*Company {
+	var companies []*Company
+	for key, val := range r.Unprocessed {
+		if !strings.HasPrefix(key, CompanyKeyPrefix) {
+			continue
+		}
+		var c Company
+		if err := json.Unmarshal([]byte(val), &c); err == nil {
+			companies = append(companies, &c)
+		}
+	}
+	sort.Slice(companies, func(i, j int) bool {
+		return companies[i].Name < companies[j].Name
+	})
+	return companies
+}
+
+// HasRole сообщает, что компания выполняла указанную роль (в snake_case).
+func (c *Company) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// LabelSuggestion хранит единичный результат поиска лейбла.
+type LabelSuggestion struct {
+	Label            *LabelProfile `json:"label"`
+	ServiceName      string        `json:"service"`
+	SourceSimilarity float64       `json:"score"`
+}
+
+// Match проверяет соответствие версии мастер-релиза фильтру.
+// Формат сравнивается без учета регистра как с основными форматами, так и с полным
+// описанием формата версии.
+func (vf *VersionFilter) Match(v *MasterVersion) bool {
+	if vf == nil {
+		return true
+	}
+	if vf.Country != "" && !strings.EqualFold(vf.Country, v.Country) {
+		return false
+	}
+	if vf.Year != 0 && vf.Year != v.Year {
+		return false
+	}
+	if vf.Format != "" {
+		format := strings.ToLower(vf.Format)
+		if !strings.Contains(strings.ToLower(v.Format), format) {
+			for _, major := range v.MajorFormats {
+				if strings.EqualFold(major, format) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// MasterVersion описывает отдельную версию (издание) мастер-релиза.
+type MasterVersion struct {
+	Title        string        `json:"title"`
+	Format       string        `json:"format,omitempty"`
+	MajorFormats []string      `json:"major_formats,omitempty"`
+	Label        string        `json:"label,omitempty"`
+	Catno        string        `json:"catno,omitempty"`
+	Country      string        `json:"country,omitempty"`
+	Released     string        `json:"released,omitempty"`
+	Year         int           `json:"year,omitempty"`
+	Status       string        `json:"status,omitempty"`
+	IDs          md.ReleaseIDs `json:"ids,omitempty"`
+}
+
+// MasterProfile содержит канонические сведения мастер-релиза и список его версий.
+type MasterProfile struct {
+	Release             *md.Release      `json:"release"`
+	MainReleaseID       string           `json:"main_release_id,omitempty"`
+	MostRecentReleaseID string           `json:"most_recent_release_id,omitempty"`
+	Versions            []*MasterVersion `json:"versions,omitempty"`
+	Pagination          *Pagination      `json:"pagination,omitempty"`
+}
+
+// CacheItem описывает запись кэша ответов Discogs API.
+type CacheItem struct {
+	Key          string    `json:"key"`
+	Size         int       `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Stored       time.Time `json:"stored"`
+	Expires      time.Time `json:"expires"`
+}
+
+// CacheInfo описывает результат административной команды "cache".
+type CacheInfo struct {
+	CacheStats
+	CacheCounters
+	Items  []*CacheItem `json:"items,omitempty"`
+	Purged int          `json:"purged,omitempty"`
+}
+
+// ServiceInfo описывает результат команды "info": версию микросервиса и источник данных
+// (базовый URL Discogs API или тип альтернативного источника).
+type ServiceInfo struct {
+	Name      string   `json:"name"`
+	BuildTime string   `json:"build_time,omitempty"`
+	Modules   []string `json:"modules,omitempty"`
+	Backend   string   `json:"backend"`
+	Cache     bool     `json:"cache"`
 }
 
 // NewAudioOnlineRequest создает новый объект запроса и возвращает ссылку на него.
@@ -31,13 +317,27 @@ func NewAudioOnlineRequest() *AudioOnlineRequest {
 	}
 }
 
-// Unwrap контроллирует значение ответа микросервиса, и, в случае ошибки,
-// печатает сведения об ошибке и останавливает процесс с запущенным клиентом.
-func (resp *AudioOnlineResponse) Unwrap() *md.SuggestionSet {
-	if resp.Error != nil {
-		srv.FailOnError(errors.New(resp.Error.Error), resp.Error.Context)
+// Err возвращает ошибку, содержащуюся в ответе микросервиса, или nil.
+func (resp *AudioOnlineResponse) Err() error {
+	if resp.Error == nil {
+		return nil
+	}
+	return &ServiceError{
+		Message:    resp.Error.Error,
+		Context:    resp.Error.Context,
+		Code:       resp.Error.Code,
+		Retryable:  resp.Error.Retryable,
+		RetryAfter: time.Duration(resp.Error.RetryAfter) * time.Second,
+	}
+}
+
+// Unwrap возвращает предложения метаданных релиза из ответа микросервиса или, если
+// ответ содержит ошибку, ошибку типа *ServiceError.
+func (resp *AudioOnlineResponse) Unwrap() (*md.SuggestionSet, error) {
+	if err := resp.Err(); err != nil {
+		return nil, err
 	}
-	return resp.SuggestionSet
+	return resp.SuggestionSet, nil
 }
 
 // CreateReleaseRequest формирует данные запроса поиска релиза по указанным метаданным.
@@ -53,6 +353,67 @@ func CreateReleaseRequest(r *md.Release) (_ string, data []byte, err error) {
 	return correlationID.String(), data, nil
 }
 
+// CreateArtistRequest формирует данные запроса сведений об исполнителе по имени или ID.
+func CreateArtistRequest(actor *Actor) (_ string, data []byte, err error) {
+	correlationID, _ := uuid.NewV4()
+	req := AudioOnlineRequest{
+		Cmd:   "artist",
+		Actor: actor}
+	data, err = json.Marshal(&req)
+	if err != nil {
+		return
+	}
+	return correlationID.String(), data, nil
+}
+
+// CreateLabelRequest формирует данные запроса сведений о лейбле по наименованию или ID.
+// Если указан `pagination`, в ответ будет включена соответствующая страница списка
+// релизов лейбла.
+func CreateLabelRequest(lbl *md.Label, pagination *Pagination) (_ string, data []byte, err error) {
+	correlationID, _ := uuid.NewV4()
+	req := AudioOnlineRequest{
+		Cmd:        "label",
+		Label:      lbl,
+		Pagination: pagination}
+	data, err = json.Marshal(&req)
+	if err != nil {
+		return
+	}
+	return correlationID.String(), data, nil
+}
+
+// CreateMasterRequest формирует данные запроса мастер-релиза по его ID в БД Discogs.
+// Если `pagination` не указан, в ответ включается полный список версий, отобранных
+// фильтром `filter`.
+func CreateMasterRequest(id string, filter *VersionFilter, pagination *Pagination) (_ string, data []byte, err error) {
+	correlationID, _ := uuid.NewV4()
+	r := md.NewRelease()
+	r.IDs[md.DiscogsMasterID] = id
+	req := AudioOnlineRequest{
+		Cmd:        "master",
+		Release:    r,
+		Versions:   filter,
+		Pagination: pagination}
+	data, err = json.Marshal(&req)
+	if err != nil {
+		return
+	}
+	return correlationID.String(), data, nil
+}
+
+// CreateCacheRequest формирует данные административного запроса к кэшу ответов Discogs API.
+func CreateCacheRequest(action, prefix string) (_ string, data []byte, err error) {
+	correlationID, _ := uuid.NewV4()
+	req := AudioOnlineRequest{
+		Cmd:   "cache",
+		Cache: &CacheRequest{Action: action, Prefix: prefix}}
+	data, err = json.Marshal(&req)
+	if err != nil {
+		return
+	}
+	return correlationID.String(), data, nil
+}
+
 // ParseReleaseAnswer разбирает ответ с предложением метаданных релиза.
 func ParseReleaseAnswer(data []byte) (_ *AudioOnlineResponse, err error) {
 	resp := AudioOnlineResponse{}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+
+	discogs "github.com/ytsiuryn/ds-discogs"
+)
+
+// Максимальная длина строки входного потока пакетного поиска.
+const maxBatchLine = 4 << 20
+
+// batchResult - результат поиска для строки входного потока пакетного поиска.
+type batchResult struct {
+	Line int `json:"line"`
+	*discogs.AudioOnlineResponse
+}
+
+// Пакетный поиск метаданных: каждая строка входного потока содержит JSON представление
+// неполного релиза (md.Release), для каждой строки выводятся предложения метаданных.
+// Ошибка поиска одного релиза не прерывает обработку остальных.
+func runBatch(args []string, stdin io.Reader, stdout io.Writer) error {
+	var opts options
+	fs := newFlagSet("batch", &opts)
+	searchFlags(fs, &opts)
+	in := fs.String("in", "", "JSONL file of incomplete releases (stdin if not set)")
+	out := fs.String("out", "", "output file (stdout if not set)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		stdin = f
+	}
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		stdout = f
+	}
+	cl, err := opts.client()
+	if err != nil {
+		return err
+	}
+	defer opts.close()
+
+	var write func(*batchResult) error
+	if opts.format == "json" {
+		enc := json.NewEncoder(stdout)
+		write = func(res *batchResult) error { return enc.Encode(res) }
+	} else {
+		tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		defer tw.Flush()
+		fmt.Fprintln(tw, "LINE\t"+strings.Join(releaseHeader, "\t"))
+		write = func(res *batchResult) error {
+			line := strconv.Itoa(res.Line)
+			if res.Error != nil {
+				_, err := fmt.Fprintf(tw, "%s\t\t\t\terror: %s\n", line, res.Error.Error)
+				return err
+			}
+			for _, row := range releaseRows(res.SuggestionSet) {
+				if _, err := fmt.Fprintln(tw, line+"\t"+strings.Join(row, "\t")); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(nil, maxBatchLine)
+	var total, failed int
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		total++
+		res := &batchResult{Line: lineNum}
+		res.AudioOnlineResponse, err = batchRelease(cl, scanner.Bytes())
+		if err != nil {
+			failed++
+			cl.Log.WithField("line", lineNum).Error(err)
+			res.AudioOnlineResponse = &discogs.AudioOnlineResponse{
+				Error: discogs.NewErrorResponse(err, "release")}
+		}
+		if err = write(res); err != nil {
+			return err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("batch: %d of %d releases failed", failed, total)
+	}
+	return nil
+}
+
+func batchRelease(cl *discogs.Discogs, line []byte) (*discogs.AudioOnlineResponse, error) {
+	r := md.NewRelease()
+	if err := json.Unmarshal(line, r); err != nil {
+		return nil, err
+	}
+	return executeRequest(cl, &discogs.AudioOnlineRequest{Cmd: "release", Release: r})
+}
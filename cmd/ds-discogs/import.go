@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	discogs "github.com/ytsiuryn/ds-discogs"
+)
+
+// Импорт XML дампов Discogs (в т.ч. сжатых gzip) в локальное хранилище автономного режима.
+func runImport(args []string, stdin io.Reader, stdout io.Writer) error {
+	var opts options
+	fs := newFlagSet("import", &opts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.dump == "" || fs.NArg() == 0 {
+		return errors.New("import: -dump DIR and dump files are required")
+	}
+	store, err := discogs.OpenDump(opts.dump)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	for _, path := range fs.Args() {
+		kind, n, err := store.ImportFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Fprintf(stdout, "%s: %d %s imported\n", path, n, kind)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+
+	discogs "github.com/ytsiuryn/ds-discogs"
+)
+
+// Поиск релиза по ID в БД Discogs, штрих-коду, номеру в каталоге лейбла или по неполным
+// данным: -search ARTIST TITLE [YEAR].
+func runRelease(args []string, stdin io.Reader, stdout io.Writer) error {
+	var opts options
+	fs := newFlagSet("release", &opts)
+	searchFlags(fs, &opts)
+	id := fs.String("id", "", "Discogs release ID")
+	barcode := fs.String("barcode", "", "release barcode")
+	catno := fs.String("catno", "", "label catalog number")
+	label := fs.String("label", "", "label name")
+	search := fs.Bool("search", false, "search by incomplete data: ARTIST TITLE [YEAR]")
+	explain := fs.Bool("explain", false, "show the similarity breakdown of suggestions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r := md.NewRelease()
+	switch {
+	case *id != "":
+		r.IDs[md.DiscogsReleaseID] = *id
+	case *search:
+		if fs.NArg() < 2 || fs.NArg() > 3 {
+			return errors.New("release: -search requires ARTIST TITLE [YEAR]")
+		}
+		r.ActorRoles.Add(fs.Arg(0), "performer")
+		r.Title = fs.Arg(1)
+		if fs.NArg() == 3 {
+			year, err := strconv.Atoi(fs.Arg(2))
+			if err != nil {
+				return errors.New("release: invalid year " + fs.Arg(2))
+			}
+			r.Year = year
+		}
+	case *barcode == "" && *catno == "":
+		return errors.New("release: one of -id, -barcode, -catno or -search is required")
+	}
+	if *barcode != "" {
+		r.Publishing.IDs[md.PublishingBarcode] = *barcode
+	}
+	if *catno != "" || *label != "" {
+		r.Publishing.Labels = append(r.Publishing.Labels, md.NewLabel(*label, *catno))
+	}
+
+	return opts.execute(&discogs.AudioOnlineRequest{Cmd: "release", Release: r, Explain: *explain}, stdout)
+}
+
+// Сведения об исполнителе по ID в БД Discogs или имени.
+func runArtist(args []string, stdin io.Reader, stdout io.Writer) error {
+	var opts options
+	fs := newFlagSet("artist", &opts)
+	id := fs.String("id", "", "Discogs artist ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	actor := &discogs.Actor{Name: joinArgs(fs), IDs: md.ActorIDs{}}
+	if *id != "" {
+		actor.IDs[md.DiscogsArtistID] = *id
+	} else if actor.Name == "" {
+		return errors.New("artist: -id or NAME is required")
+	}
+	return opts.execute(&discogs.AudioOnlineRequest{Cmd: "artist", Actor: actor}, stdout)
+}
+
+// Сведения о лейбле по ID в БД Discogs или наименованию и, при указании страницы,
+// список его релизов.
+func runLabel(args []string, stdin io.Reader, stdout io.Writer) error {
+	var opts options
+	fs := newFlagSet("label", &opts)
+	id := fs.String("id", "", "Discogs label ID")
+	page := fs.Int("page", 0, "page of the label release list")
+	perPage := fs.Int("per-page", 0, "label releases per page")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	lbl := md.NewLabel(joinArgs(fs), "")
+	if *id != "" {
+		lbl.IDs[md.DiscogsLabelID] = *id
+	} else if lbl.Label == "" {
+		return errors.New("label: -id or NAME is required")
+	}
+	req := &discogs.AudioOnlineRequest{Cmd: "label", Label: lbl}
+	if *page > 0 || *perPage > 0 {
+		req.Pagination = &discogs.Pagination{Page: *page, PerPage: *perPage}
+	}
+	return opts.execute(req, stdout)
+}
+
+// Мастер-релиз по ID в БД Discogs и список его версий с фильтрацией.
+func runMaster(args []string, stdin io.Reader, stdout io.Writer) error {
+	var opts options
+	fs := newFlagSet("master", &opts)
+	var filter discogs.VersionFilter
+	fs.StringVar(&filter.Format, "format", "", "version format filter (e.g. Vinyl)")
+	fs.StringVar(&filter.Country, "country", "", "version country filter")
+	fs.IntVar(&filter.Year, "year", 0, "version release year filter")
+	page := fs.Int("page", 0, "page of the version list (all versions if not set)")
+	perPage := fs.Int("per-page", 0, "versions per page")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("master: ID is required")
+	}
+	r := md.NewRelease()
+	r.IDs[md.DiscogsMasterID] = fs.Arg(0)
+	req := &discogs.AudioOnlineRequest{Cmd: "master", Release: r, Versions: &filter}
+	if *page > 0 || *perPage > 0 {
+		req.Pagination = &discogs.Pagination{Page: *page, PerPage: *perPage}
+	}
+	return opts.execute(req, stdout)
+}
@@ -0,0 +1,201 @@
+// Утилита командной строки для работы с Discogs: запуск микросервиса, разовые запросы
+// сведений о релизах, исполнителях, лейблах и мастер-релизах, пакетный поиск метаданных
+// для списка неполных релизов и импорт дампов Discogs для автономного режима.
+//
+// Использование:
+//
+//	ds-discogs serve   [search options] [-amqp connstr | -http addr]
+//	ds-discogs release [search options] [-explain] -id ID | -barcode CODE | -catno CATNO [-label LABEL] | -search ARTIST TITLE [YEAR]
+//	ds-discogs artist  -id ID | NAME
+//	ds-discogs label   [-page N] [-per-page N] -id ID | NAME
+//	ds-discogs master  [-format F] [-country C] [-year Y] [-page N] [-per-page N] ID
+//	ds-discogs batch   [search options] [-in FILE] [-out FILE]
+//	ds-discogs import  -dump DIR FILE...
+//
+// Параметры поиска релизов: -min-score, -max-results, -candidates, -pages и -no-masters.
+//
+// Авторизация в Discogs API задается переменными окружения DISCOGS_APP и
+// DISCOGS_PERSONAL_TOKEN.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	discogs "github.com/ytsiuryn/ds-discogs"
+)
+
+// Размеры кэша ответов Discogs API, подключаемого опцией -cache.
+const (
+	memCacheEntries = 1000
+	memCacheSize    = 64 << 20
+	diskCacheSize   = 1 << 30
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = []*command{
+	{"serve", "run the microservice (RabbitMQ or HTTP)", runServe},
+	{"release", "look up a release by ID, barcode, catalog number or incomplete data", runRelease},
+	{"artist", "look up an artist by ID or name", runArtist},
+	{"label", "look up a label by ID or name", runLabel},
+	{"master", "look up a master release and its versions", runMaster},
+	{"batch", "find suggestions for a JSONL stream of incomplete releases", runBatch},
+	{"import", "import Discogs XML data dumps for offline mode", runImport},
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "ds-discogs:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return flag.ErrHelp
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdin, stdout)
+		}
+	}
+	usage(os.Stderr)
+	return fmt.Errorf("unknown command: %s", args[0])
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ds-discogs <command> [options] [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(w, "\nRun 'ds-discogs <command> -h' for command options.")
+}
+
+// options - общие параметры команд: источник данных, кэш и формат вывода.
+type options struct {
+	format  string
+	cache   string
+	dump    string
+	baseURL string
+	verbose bool
+	store   *discogs.DumpStore
+	// параметры поиска релизов (см. searchFlags)
+	search    discogs.SearchOptions
+	noMasters bool
+}
+
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&opts.format, "o", "table", "output format: table or json")
+	fs.StringVar(&opts.cache, "cache", "", "directory of the Discogs API response cache")
+	fs.StringVar(&opts.dump, "dump", "", "directory of the imported Discogs data dumps (offline mode)")
+	fs.StringVar(&opts.baseURL, "base-url", "", "Discogs API compatible server URL")
+	fs.BoolVar(&opts.verbose, "v", false, "debug logging")
+	return fs
+}
+
+// Параметры поиска релизов для команд, выполняющих поиск. Незаданные параметры
+// сохраняют значения по умолчанию сервиса.
+func searchFlags(fs *flag.FlagSet, opts *options) {
+	fs.Func("min-score", "minimum similarity of suggestions (0..1)", func(value string) error {
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		opts.search.MinFullResult = &score
+		return nil
+	})
+	fs.IntVar(&opts.search.MaxSuggestions, "max-results", 0, "maximum number of suggestions")
+	fs.IntVar(&opts.search.MaxCandidates, "candidates", 0, "number of candidates fetched for full comparison")
+	fs.IntVar(&opts.search.SearchPages, "pages", 0, "number of search result pages to scan")
+	fs.BoolVar(&opts.noMasters, "no-masters", false, "do not fetch master releases of candidates")
+}
+
+// Клиент Discogs с параметрами командной строки.
+func (opts *options) client() (*discogs.Discogs, error) {
+	if opts.format != "table" && opts.format != "json" {
+		return nil, fmt.Errorf("unknown output format: %s", opts.format)
+	}
+	var clOpts []discogs.Option
+	if opts.dump != "" {
+		store, err := discogs.OpenDump(opts.dump)
+		if err != nil {
+			return nil, err
+		}
+		opts.store = store
+		clOpts = append(clOpts, discogs.WithDump(store))
+	}
+	if opts.cache != "" {
+		disk, err := discogs.NewDiskCache(opts.cache, diskCacheSize)
+		if err != nil {
+			return nil, err
+		}
+		clOpts = append(clOpts, discogs.WithCache(discogs.NewLayeredCache(
+			discogs.NewMemoryCache(memCacheEntries, memCacheSize), disk)))
+	}
+	if opts.baseURL != "" {
+		clOpts = append(clOpts, discogs.WithBaseURL(opts.baseURL))
+	}
+	if opts.noMasters {
+		fetchMasters := false
+		opts.search.FetchMasters = &fetchMasters
+	}
+	clOpts = append(clOpts, discogs.WithSearchOptions(opts.search))
+	cl := discogs.New(os.Getenv("DISCOGS_APP"), os.Getenv("DISCOGS_PERSONAL_TOKEN"), clOpts...)
+	if opts.verbose {
+		cl.Log.SetLevel(log.DebugLevel)
+	} else {
+		cl.Log.SetLevel(log.WarnLevel)
+	}
+	return cl, nil
+}
+
+// Освобождение ресурсов клиента.
+func (opts *options) close() {
+	if opts.store != nil {
+		opts.store.Close()
+	}
+}
+
+// Выполнение запроса к клиенту без участия брокера сообщений и вывод результата.
+func (opts *options) execute(req *discogs.AudioOnlineRequest, stdout io.Writer) error {
+	cl, err := opts.client()
+	if err != nil {
+		return err
+	}
+	defer opts.close()
+	resp, err := executeRequest(cl, req)
+	if err != nil {
+		return err
+	}
+	return writeResponse(stdout, opts.format, resp)
+}
+
+func executeRequest(cl *discogs.Discogs, req *discogs.AudioOnlineRequest) (*discogs.AudioOnlineResponse, error) {
+	data, err := cl.Execute(req)
+	if err != nil {
+		return nil, err
+	}
+	return discogs.ParseReleaseAnswer(data)
+}
+
+// Аргументы команды, заданные без флагов, объединяются в одну строку (имя, наименование).
+func joinArgs(fs *flag.FlagSet) string {
+	return strings.TrimSpace(strings.Join(fs.Args(), " "))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+// Каталог хранилища с импортированными фикстурами дампов Discogs.
+func importTestDump(t *testing.T) string {
+	dir := t.TempDir()
+	files, err := filepath.Glob(filepath.Join("..", "..", "testdata", "dump", "*.xml"))
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, run(append([]string{"import", "-dump", dir}, files...), nil, &out))
+	assert.Contains(t, out.String(), "3 releases imported")
+	return dir
+}
+
+func TestRelease(t *testing.T) {
+	dir := importTestDump(t)
+
+	var out bytes.Buffer
+	require.NoError(t, run([]string{"release", "-dump", dir, "-o", "json", "-id", "1"}, nil, &out))
+	var resp struct {
+		SuggestionSet *md.SuggestionSet `json:"suggestion_set"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &resp))
+	require.Len(t, resp.SuggestionSet.Suggestions, 1)
+	assert.Equal(t, "Stockholm", resp.SuggestionSet.Suggestions[0].Release.Title)
+
+	out.Reset()
+	require.NoError(t, run(
+		[]string{"release", "-dump", dir, "-explain", "-search", "The Persuader", "Stockholm"}, nil, &out))
+	assert.Regexp(t, `(?m)^1 +title +Stockholm +Stockholm +1\.00`, out.String())
+
+	out.Reset()
+	require.NoError(t, run([]string{"master", "-dump", dir, "-format", "CD", "5427"}, nil, &out))
+	assert.Contains(t, out.String(), "SK032CD")
+	assert.NotContains(t, out.String(), "SK032 ")
+
+	assert.Error(t, run([]string{"release", "-dump", dir}, nil, &out))
+	assert.Error(t, run([]string{"x"}, nil, &out))
+}
+
+func TestBatch(t *testing.T) {
+	dir := importTestDump(t)
+
+	in := strings.NewReader(`{"title": "Stockholm", "actors_roles": {"The Persuader": ["performer"]}}
+
+{"title": `)
+	var out bytes.Buffer
+	err := run([]string{"batch", "-dump", dir}, in, &out)
+	assert.EqualError(t, err, "batch: 1 of 2 releases failed")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "LINE"))
+	assert.Regexp(t, `^1 +1\.00 +1 +The Persuader +Stockholm +1999`, lines[1])
+	assert.Regexp(t, `^3 .*error:`, lines[3])
+}
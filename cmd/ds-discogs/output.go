@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+
+	discogs "github.com/ytsiuryn/ds-discogs"
+)
+
+var releaseHeader = []string{"SCORE", "ID", "ARTIST", "TITLE", "YEAR", "COUNTRY", "LABEL", "CATNO"}
+
+// Вывод ответа микросервиса в формате JSON или в виде таблицы.
+func writeResponse(w io.Writer, format string, resp *discogs.AudioOnlineResponse) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp)
+	}
+	switch {
+	case resp.SuggestionSet != nil:
+		if err := writeTable(w, releaseHeader, releaseRows(resp.SuggestionSet)); err != nil || resp.Explanations == nil {
+			return err
+		}
+		fmt.Fprintln(w)
+		return writeTable(w, []string{"ID", "FIELD", "REQUESTED", "CANDIDATE", "SCORE"},
+			explanationRows(resp.SuggestionSet, resp.Explanations))
+	case resp.Artists != nil:
+		return writeTable(w, []string{"SCORE", "ID", "NAME", "REAL NAME"}, artistRows(resp.Artists))
+	case resp.Labels != nil:
+		return writeLabels(w, resp.Labels)
+	case resp.Master != nil:
+		return writeMaster(w, resp.Master)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(resp)
+}
+
+func writeTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func releaseRows(set *md.SuggestionSet) (rows [][]string) {
+	for _, s := range set.Suggestions {
+		r := s.Release
+		var label, catno string
+		if r.Publishing != nil && len(r.Publishing.Labels) > 0 {
+			label, catno = r.Publishing.Labels[0].Label, r.Publishing.Labels[0].Catno
+		}
+		rows = append(rows, []string{
+			score(s.SourceSimilarity),
+			r.IDs[md.DiscogsReleaseID],
+			r.ActorRoles.Filter(md.IsPerformer).First(),
+			r.Title,
+			year(r.Year),
+			r.Country,
+			label,
+			catno,
+		})
+	}
+	return
+}
+
+// Оценки полей предложений в порядке следования предложений.
+func explanationRows(set *md.SuggestionSet, explanations map[string]*discogs.Explanation) (rows [][]string) {
+	for _, s := range set.Suggestions {
+		id := s.Release.IDs[md.DiscogsReleaseID]
+		e, ok := explanations[id]
+		if !ok {
+			continue
+		}
+		for _, f := range e.Fields {
+			rows = append(rows, []string{id, f.Field, f.Requested, f.Candidate, score(f.Score)})
+		}
+	}
+	return
+}
+
+func artistRows(artists []*discogs.ArtistSuggestion) (rows [][]string) {
+	for _, s := range artists {
+		rows = append(rows, []string{
+			score(s.SourceSimilarity),
+			s.Artist.IDs[md.DiscogsArtistID],
+			s.Artist.Name,
+			s.Artist.RealName,
+		})
+	}
+	return
+}
+
+func writeLabels(w io.Writer, labels []*discogs.LabelSuggestion) error {
+	var rows [][]string
+	for _, s := range labels {
+		var parent string
+		if s.Label.ParentLabel != nil {
+			parent = s.Label.ParentLabel.Name
+		}
+		rows = append(rows, []string{
+			score(s.SourceSimilarity),
+			s.Label.IDs[md.DiscogsLabelID],
+			s.Label.Name,
+			parent,
+		})
+	}
+	if err := writeTable(w, []string{"SCORE", "ID", "NAME", "PARENT"}, rows); err != nil {
+		return err
+	}
+	if len(labels) == 0 || len(labels[0].Label.Releases) == 0 {
+		return nil
+	}
+	lbl := labels[0].Label
+	rows = nil
+	for _, r := range lbl.Releases {
+		rows = append(rows, []string{
+			r.IDs[md.DiscogsReleaseID], r.Artist, r.Title, year(r.Year), r.Format, r.Catno})
+	}
+	fmt.Fprintf(w, "\n%s releases%s:\n", lbl.Name, page(lbl.Pagination))
+	return writeTable(w, []string{"ID", "ARTIST", "TITLE", "YEAR", "FORMAT", "CATNO"}, rows)
+}
+
+func writeMaster(w io.Writer, master *discogs.MasterProfile) error {
+	r := master.Release
+	fmt.Fprintf(w, "%s - %s (%s)\n",
+		r.ActorRoles.Filter(md.IsPerformer).First(), r.Title, year(r.Year))
+	if master.MainReleaseID != "" {
+		fmt.Fprintf(w, "main release: %s\n", master.MainReleaseID)
+	}
+	var rows [][]string
+	for _, v := range master.Versions {
+		rows = append(rows, []string{
+			v.IDs[md.DiscogsReleaseID], v.Title, v.Format, v.Label, v.Catno, v.Country, year(v.Year)})
+	}
+	fmt.Fprintf(w, "\nversions%s:\n", page(master.Pagination))
+	return writeTable(w, []string{"ID", "TITLE", "FORMAT", "LABEL", "CATNO", "COUNTRY", "YEAR"}, rows)
+}
+
+func score(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func year(y int) string {
+	if y == 0 {
+		return ""
+	}
+	return strconv.Itoa(y)
+}
+
+func page(p *discogs.Pagination) string {
+	if p == nil || p.Pages <= 1 {
+		return ""
+	}
+	return fmt.Sprintf(" (page %d of %d, %d items)", p.Page, p.Pages, p.Items)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+
+	srv "github.com/ytsiuryn/ds-microservice"
+)
+
+// Запуск микросервиса с обменом сообщениями через RabbitMQ или, если указан адрес
+// -http, в режиме HTTP сервера.
+func runServe(args []string, stdin io.Reader, stdout io.Writer) error {
+	var opts options
+	fs := newFlagSet("serve", &opts)
+	searchFlags(fs, &opts)
+	connstr := fs.String("amqp", srv.DefaultRabbitMQConnStr, "message broker connection string")
+	addr := fs.String("http", "", "HTTP server address (e.g. :8080) instead of the message broker")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("serve: unexpected arguments")
+	}
+	cl, err := opts.client()
+	if err != nil {
+		return err
+	}
+	defer opts.close()
+	if !opts.verbose {
+		cl.Log.SetLevel(log.InfoLevel)
+	}
+	cl.Log.Info(cl.Name + " starting..")
+	if *addr != "" {
+		cl.StartHTTP(*addr)
+	} else {
+		cl.StartWithConnection(*connstr)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package discogstest
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Максимальная длина имени файла записанного ответа (без расширения).
+const maxCassetteName = 120
+
+// Recorder - http.RoundTripper, который в режиме записи передает запросы реальному серверу
+// и сохраняет успешные ответы в каталог, а в режиме воспроизведения отвечает ранее
+// записанными ответами без обращения к сети.
+//
+// Сохраняется только тело ответа, поэтому записи не содержат заголовков авторизации и
+// могут храниться в репозитории рядом с тестами. Имя файла записи определяется функцией
+// CassetteName по URL запроса.
+type Recorder struct {
+	Dir    string
+	Record bool
+	// Transport используется в режиме записи (по умолчанию http.DefaultTransport).
+	Transport http.RoundTripper
+}
+
+// NewRecorder создает транспорт записи (`record` = true) или воспроизведения ответов
+// в каталоге `dir`.
+func NewRecorder(dir string, record bool) *Recorder {
+	return &Recorder{Dir: dir, Record: record}
+}
+
+// RoundTrip выполняет (или воспроизводит) запрос.
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := filepath.Join(rec.Dir, CassetteName(req.URL)+".json")
+	if rec.Record {
+		return rec.record(req, path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		data, _ = json.Marshal(map[string]string{
+			"message": "no recorded response for " + req.URL.RequestURI()})
+		return response(req, http.StatusNotFound, data), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response(req, http.StatusOK, data), nil
+}
+
+func (rec *Recorder) record(req *http.Request, path string) (*http.Response, error) {
+	transport := rec.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || req.Method != http.MethodGet {
+		return resp, err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	var indented bytes.Buffer
+	if json.Indent(&indented, data, "", "  ") == nil {
+		data = indented.Bytes()
+	}
+	if err = os.MkdirAll(rec.Dir, 0755); err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// CassetteName формирует имя файла записи ответа из пути и параметров URL, например,
+// "releases_4139588" или "database_search_q=harvest&type=label".
+func CassetteName(u *url.URL) string {
+	name := strings.ReplaceAll(strings.Trim(u.Path, "/"), "/", "_")
+	if query := u.Query().Encode(); query != "" {
+		name += "_" + query
+	}
+	name = strings.Map(func(ch rune) rune {
+		if unicode.IsLetter(ch) || unicode.IsDigit(ch) || strings.ContainsRune("_-.=&+", ch) {
+			return ch
+		}
+		return '_'
+	}, name)
+	if len(name) > maxCassetteName {
+		sum := sha1.Sum([]byte(name))
+		name = strings.ToValidUTF8(name[:maxCassetteName-9], "") + "_" + hex.EncodeToString(sum[:4])
+	}
+	return name
+}
+
+func response(req *http.Request, status int, data []byte) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}
+}
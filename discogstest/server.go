@@ -0,0 +1,562 @@
+// Package discogstest предоставляет подделку Discogs API для тестов: HTTP сервер,
+// обслуживающий фикстуры (документы ответов Discogs API) без обращения к сети.
+//
+// Сервер поддерживает поиск с фильтрацией по параметрам запроса, получение релизов,
+// мастер-релизов и их версий, исполнителей, лейблов и их релизов, постраничный вывод
+// списков, заголовки бюджета запросов Discogs, условные запросы (ETag) и имитацию ошибок.
+package discogstest
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Rate limit headers
+const (
+	RateHeaderKey          = "X-Discogs-Ratelimit"
+	RateUsedHeaderKey      = "X-Discogs-Ratelimit-Used"
+	RateRemainingHeaderKey = "X-Discogs-Ratelimit-Remaining"
+)
+
+// Server constants
+const (
+	// DefaultRateLimit - бюджет запросов в минуту авторизованного клиента Discogs.
+	DefaultRateLimit = 60
+	// DefaultPerPage - размер страницы списков по умолчанию.
+	DefaultPerPage = 50
+	// MaxPerPage - максимальный размер страницы списков.
+	MaxPerPage = 100
+)
+
+var notFound = map[string]string{
+	"release": "Release not found.",
+	"master":  "Master Release not found.",
+	"artist":  "Artist not found.",
+	"label":   "Label not found.",
+}
+
+type ref struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Catno string `json:"catno"`
+}
+
+type formatDoc struct {
+	Name         string   `json:"name"`
+	Descriptions []string `json:"descriptions"`
+}
+
+// Поля документов Discogs API, необходимые для поиска.
+type entityDoc struct {
+	ID        int64       `json:"id"`
+	Name      string      `json:"name"`
+	Title     string      `json:"title"`
+	Year      int         `json:"year"`
+	Country   string      `json:"country"`
+	MasterID  int64       `json:"master_id"`
+	Thumb     string      `json:"thumb"`
+	Artists   []ref       `json:"artists"`
+	Labels    []ref       `json:"labels"`
+	Formats   []formatDoc `json:"formats"`
+	Tracklist []struct {
+		Title string `json:"title"`
+	} `json:"tracklist"`
+	Genres      []string `json:"genres"`
+	Styles      []string `json:"styles"`
+	Identifiers []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"identifiers"`
+}
+
+type entity struct {
+	kind string
+	doc  *entityDoc
+	data []byte
+}
+
+type failure struct {
+	status     int
+	retryAfter int
+}
+
+// Server - подделка Discogs API.
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	entities      map[string]map[string]*entity
+	order         []*entity
+	versions      map[string][]json.RawMessage
+	labelReleases map[string][]json.RawMessage
+	failures      []failure
+	requests      []string
+	windowStart   time.Time
+	used          int
+	rateLimit     int
+}
+
+// NewServer создает и запускает подделку Discogs API. Сервер должен быть остановлен
+// методом Close.
+func NewServer() *Server {
+	s := &Server{
+		rateLimit:     DefaultRateLimit,
+		entities:      map[string]map[string]*entity{},
+		versions:      map[string][]json.RawMessage{},
+		labelReleases: map[string][]json.RawMessage{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// BaseURL возвращает базовый URL подделки Discogs API.
+func (s *Server) BaseURL() string {
+	return s.URL + "/"
+}
+
+// AddRelease добавляет документ релиза (ответ "GET /releases/{id}").
+func (s *Server) AddRelease(data []byte) error {
+	return s.add("release", data)
+}
+
+// AddMaster добавляет документ мастер-релиза (ответ "GET /masters/{id}").
+func (s *Server) AddMaster(data []byte) error {
+	return s.add("master", data)
+}
+
+// AddArtist добавляет документ исполнителя (ответ "GET /artists/{id}").
+func (s *Server) AddArtist(data []byte) error {
+	return s.add("artist", data)
+}
+
+// AddLabel добавляет документ лейбла (ответ "GET /labels/{id}").
+func (s *Server) AddLabel(data []byte) error {
+	return s.add("label", data)
+}
+
+// AddVersions добавляет версии мастер-релиза из страницы списка версий
+// (ответ "GET /masters/{id}/versions").
+func (s *Server) AddVersions(masterID string, data []byte) error {
+	var page struct {
+		Versions []json.RawMessage `json:"versions"`
+	}
+	if err := json.Unmarshal(data, &page); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[masterID] = append(s.versions[masterID], page.Versions...)
+	return nil
+}
+
+// AddLabelReleases добавляет релизы лейбла из страницы списка релизов
+// (ответ "GET /labels/{id}/releases").
+func (s *Server) AddLabelReleases(labelID string, data []byte) error {
+	var page struct {
+		Releases []json.RawMessage `json:"releases"`
+	}
+	if err := json.Unmarshal(data, &page); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.labelReleases[labelID] = append(s.labelReleases[labelID], page.Releases...)
+	return nil
+}
+
+// SetRateLimit задает бюджет запросов в минуту (по умолчанию DefaultRateLimit).
+// При его исчерпании сервер отвечает кодом 429. Нулевое значение отключает ограничение
+// и заголовки бюджета запросов.
+func (s *Server) SetRateLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit = limit
+}
+
+// FailNext задает ответ кодом `status` на `n` следующих запросов. Для кода 429
+// заголовок Retry-After принимает значение `retryAfter` (в секундах), если оно положительно.
+func (s *Server) FailNext(n, status, retryAfter int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, failure{status: status, retryAfter: retryAfter})
+	}
+}
+
+// Requests возвращает пути и параметры всех полученных сервером запросов.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) add(kind string, data []byte) error {
+	var doc entityDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entities[kind] == nil {
+		s.entities[kind] = map[string]*entity{}
+	}
+	e := &entity{kind: kind, doc: &doc, data: data}
+	id := strconv.FormatInt(doc.ID, 10)
+	if _, ok := s.entities[kind][id]; !ok {
+		s.order = append(s.order, e)
+	} else {
+		for i, known := range s.order {
+			if known.kind == kind && known.doc.ID == doc.ID {
+				s.order[i] = e
+			}
+		}
+	}
+	s.entities[kind][id] = e
+	return nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.URL.RequestURI())
+
+	if s.rateLimit > 0 {
+		if now := time.Now(); now.Sub(s.windowStart) >= time.Minute {
+			s.windowStart, s.used = now, 0
+		}
+		s.used++
+		w.Header().Set(RateHeaderKey, strconv.Itoa(s.rateLimit))
+		w.Header().Set(RateUsedHeaderKey, strconv.Itoa(s.used))
+		remaining := s.rateLimit - s.used
+		if remaining < 0 {
+			remaining = 0
+		}
+		w.Header().Set(RateRemainingHeaderKey, strconv.Itoa(remaining))
+		if s.used > s.rateLimit {
+			retryAfter := int((time.Minute-time.Since(s.windowStart))/time.Second) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeError(w, http.StatusTooManyRequests, "You are making requests too quickly.")
+			return
+		}
+	}
+	if len(s.failures) > 0 {
+		f := s.failures[0]
+		s.failures = s.failures[1:]
+		if f.status == http.StatusTooManyRequests && f.retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(f.retryAfter))
+		}
+		writeError(w, f.status, http.StatusText(f.status))
+		return
+	}
+
+	status, data := s.route(strings.Split(strings.Trim(r.URL.Path, "/"), "/"), r.URL.Query())
+	if status != http.StatusOK {
+		writeError(w, status, string(data))
+		return
+	}
+	sum := sha1.Sum(data)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodHead {
+		w.Write(data)
+	}
+}
+
+// Маршрутизация запроса. Для кодов ошибок вместо данных возвращается сообщение.
+func (s *Server) route(path []string, query url.Values) (int, []byte) {
+	switch {
+	case len(path) == 1 && path[0] == "":
+		return http.StatusOK, []byte(`{"hello": "Welcome to the Discogs API."}`)
+	case len(path) == 2 && path[0] == "database" && path[1] == "search":
+		return s.search(query)
+	case len(path) == 2:
+		kind := strings.TrimSuffix(path[0], "s")
+		if e, ok := s.entities[kind][path[1]]; ok {
+			return http.StatusOK, e.data
+		}
+		if message, ok := notFound[kind]; ok {
+			return http.StatusNotFound, []byte(message)
+		}
+	case len(path) == 3 && path[0] == "masters" && path[2] == "versions":
+		if _, ok := s.entities["master"][path[1]]; !ok && s.versions[path[1]] == nil {
+			return http.StatusNotFound, []byte(notFound["master"])
+		}
+		return s.page("versions", filterVersions(s.versions[path[1]], query), query)
+	case len(path) == 3 && path[0] == "labels" && path[2] == "releases":
+		if _, ok := s.entities["label"][path[1]]; !ok && s.labelReleases[path[1]] == nil {
+			return http.StatusNotFound, []byte(notFound["label"])
+		}
+		return s.page("releases", s.labelReleases[path[1]], query)
+	}
+	return http.StatusNotFound, []byte("The requested resource was not found.")
+}
+
+// Поиск по параметрам type, q, title, release_title, artist, label, catno, barcode,
+// year, country, format и track.
+func (s *Server) search(query url.Values) (int, []byte) {
+	var results []json.RawMessage
+	for _, e := range s.order {
+		if t := query.Get("type"); t != "" && t != e.kind {
+			continue
+		}
+		if !e.match(query) {
+			continue
+		}
+		data, err := json.Marshal(e.searchResult())
+		if err != nil {
+			return http.StatusInternalServerError, []byte(err.Error())
+		}
+		results = append(results, data)
+	}
+	return s.page("results", results, query)
+}
+
+// Страница списка в формате Discogs API.
+func (s *Server) page(key string, items []json.RawMessage, query url.Values) (int, []byte) {
+	page, perPage := 1, DefaultPerPage
+	if n, err := strconv.Atoi(query.Get("page")); err == nil && n > 0 {
+		page = n
+	}
+	if n, err := strconv.Atoi(query.Get("per_page")); err == nil && n > 0 {
+		perPage = n
+		if perPage > MaxPerPage {
+			perPage = MaxPerPage
+		}
+	}
+	start := (page - 1) * perPage
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + perPage
+	if end > len(items) {
+		end = len(items)
+	}
+	pageItems := items[start:end]
+	if pageItems == nil {
+		pageItems = []json.RawMessage{}
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"pagination": map[string]int{
+			"page":     page,
+			"pages":    (len(items) + perPage - 1) / perPage,
+			"per_page": perPage,
+			"items":    len(items),
+		},
+		key: pageItems,
+	})
+	if err != nil {
+		return http.StatusInternalServerError, []byte(err.Error())
+	}
+	return http.StatusOK, data
+}
+
+func (e *entity) match(query url.Values) bool {
+	doc := e.doc
+	var artists, labels, catnos, barcodes, formats []string
+	for _, a := range doc.Artists {
+		artists = append(artists, a.Name)
+	}
+	for _, lbl := range doc.Labels {
+		labels = append(labels, lbl.Name)
+		catnos = append(catnos, lbl.Catno)
+	}
+	for _, id := range doc.Identifiers {
+		if id.Type == "Barcode" {
+			barcodes = append(barcodes, digits(id.Value))
+		}
+	}
+	for _, f := range doc.Formats {
+		formats = append(formats, f.Name)
+		formats = append(formats, f.Descriptions...)
+	}
+	title := doc.Title + doc.Name
+	all := append(append([]string{title}, artists...), labels...)
+	switch {
+	case !containsTerms(all, query.Get("q")),
+		!containsTerms([]string{title}, query.Get("title")),
+		!containsTerms([]string{title}, query.Get("release_title")),
+		!containsTerms(labels, query.Get("label")),
+		!containsTerms(formats, query.Get("format")):
+		return false
+	}
+	for _, name := range query["artist"] {
+		if !containsTerms(artists, name) {
+			return false
+		}
+	}
+	if catno := query.Get("catno"); catno != "" && !containsNormalized(catnos, catno, alnum) {
+		return false
+	}
+	if barcode := query.Get("barcode"); barcode != "" && !containsNormalized(barcodes, barcode, digits) {
+		return false
+	}
+	if year := query.Get("year"); year != "" && year != strconv.Itoa(doc.Year) {
+		return false
+	}
+	if country := query.Get("country"); country != "" && !strings.EqualFold(country, doc.Country) {
+		return false
+	}
+	if track := query.Get("track"); track != "" {
+		for _, tr := range doc.Tracklist {
+			if containsTerms([]string{tr.Title}, track) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (e *entity) searchResult() map[string]interface{} {
+	doc := e.doc
+	id := strconv.FormatInt(doc.ID, 10)
+	result := map[string]interface{}{
+		"id":           doc.ID,
+		"type":         e.kind,
+		"title":        doc.Title + doc.Name,
+		"thumb":        doc.Thumb,
+		"resource_url": "https://api.discogs.com/" + e.kind + "s/" + id,
+		"uri":          "/" + e.kind + "/" + id,
+	}
+	if e.kind != "release" && e.kind != "master" {
+		return result
+	}
+	var artists, labels, formats, barcodes []string
+	for _, a := range doc.Artists {
+		artists = append(artists, a.Name)
+	}
+	if len(artists) > 0 {
+		result["title"] = strings.Join(artists, ", ") + " - " + doc.Title
+	}
+	for _, lbl := range doc.Labels {
+		labels = append(labels, lbl.Name)
+	}
+	if len(doc.Labels) > 0 {
+		result["catno"] = doc.Labels[0].Catno
+	}
+	for _, f := range doc.Formats {
+		formats = append(formats, f.Name)
+		formats = append(formats, f.Descriptions...)
+	}
+	for _, id := range doc.Identifiers {
+		if id.Type == "Barcode" {
+			barcodes = append(barcodes, id.Value)
+		}
+	}
+	if doc.Year != 0 {
+		result["year"] = strconv.Itoa(doc.Year)
+	}
+	if doc.MasterID != 0 {
+		result["master_id"] = doc.MasterID
+		result["master_url"] = "https://api.discogs.com/masters/" + strconv.FormatInt(doc.MasterID, 10)
+	}
+	result["label"] = labels
+	result["format"] = formats
+	result["barcode"] = barcodes
+	result["country"] = doc.Country
+	result["genre"] = doc.Genres
+	result["style"] = doc.Styles
+	return result
+}
+
+// Отбор версий мастер-релиза по параметрам format, country и released.
+func filterVersions(versions []json.RawMessage, query url.Values) []json.RawMessage {
+	var ret []json.RawMessage
+	for _, data := range versions {
+		var v struct {
+			Format       string   `json:"format"`
+			MajorFormats []string `json:"major_formats"`
+			Country      string   `json:"country"`
+			Released     string   `json:"released"`
+		}
+		if json.Unmarshal(data, &v) != nil {
+			continue
+		}
+		if !containsTerms(append(v.MajorFormats, v.Format), query.Get("format")) {
+			continue
+		}
+		if country := query.Get("country"); country != "" && !strings.EqualFold(country, v.Country) {
+			continue
+		}
+		if released := query.Get("released"); released != "" && !strings.HasPrefix(v.Released, released) {
+			continue
+		}
+		ret = append(ret, data)
+	}
+	return ret
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	data, _ := json.Marshal(map[string]string{"message": message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// Проверка вхождения всех слов `text` в слова значений без учета регистра.
+func containsTerms(values []string, text string) bool {
+	terms := words(text)
+	if len(terms) == 0 {
+		return true
+	}
+	known := map[string]bool{}
+	for _, v := range values {
+		for _, word := range words(v) {
+			known[word] = true
+		}
+	}
+	for _, term := range terms {
+		if !known[term] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsNormalized(values []string, value string, normalize func(string) string) bool {
+	value = normalize(value)
+	for _, v := range values {
+		if normalize(v) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(ch rune) bool {
+		return !unicode.IsLetter(ch) && !unicode.IsDigit(ch)
+	})
+}
+
+func alnum(s string) string {
+	return strings.Map(func(ch rune) rune {
+		if unicode.IsLetter(ch) || unicode.IsDigit(ch) {
+			return unicode.ToUpper(ch)
+		}
+		return -1
+	}, s)
+}
+
+func digits(s string) string {
+	return strings.Map(func(ch rune) rune {
+		if unicode.IsDigit(ch) {
+			return ch
+		}
+		return -1
+	}, s)
+}
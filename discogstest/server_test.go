@@ -0,0 +1,141 @@
+package discogstest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type searchPage struct {
+	Pagination struct {
+		Page    int `json:"page"`
+		Pages   int `json:"pages"`
+		PerPage int `json:"per_page"`
+		Items   int `json:"items"`
+	} `json:"pagination"`
+	Results []struct {
+		ID      int64    `json:"id"`
+		Type    string   `json:"type"`
+		Title   string   `json:"title"`
+		Year    string   `json:"year"`
+		Label   []string `json:"label"`
+		Catno   string   `json:"catno"`
+		Barcode []string `json:"barcode"`
+	} `json:"results"`
+}
+
+func newTestServer(t *testing.T) *Server {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	for _, fixture := range []struct {
+		name string
+		add  func([]byte) error
+	}{
+		{"release", s.AddRelease},
+		{"master", s.AddMaster},
+		{"artist", s.AddArtist},
+		{"label", s.AddLabel},
+		{"versions", func(data []byte) error { return s.AddVersions("10362", data) }},
+		{"label_releases", func(data []byte) error { return s.AddLabelReleases("2", data) }},
+	} {
+		data, err := ioutil.ReadFile(filepath.Join("..", "testdata", fixture.name+".json"))
+		require.NoError(t, err)
+		require.NoError(t, fixture.add(data))
+	}
+	return s
+}
+
+func get(t *testing.T, s *Server, uri string, out interface{}) *http.Response {
+	resp, err := http.Get(s.BaseURL() + uri)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp
+}
+
+func TestSearch(t *testing.T) {
+	s := newTestServer(t)
+
+	var page searchPage
+	get(t, s, "database/search?type=release&title=dark+side&artist=Pink+Floyd&year=1977", &page)
+	require.Len(t, page.Results, 1)
+	assert.Equal(t, int64(4139588), page.Results[0].ID)
+	assert.Equal(t, "Pink Floyd - The Dark Side Of The Moon", page.Results[0].Title)
+	assert.Equal(t, "SHVL 804", page.Results[0].Catno)
+
+	page = searchPage{}
+	get(t, s, "database/search?catno=shvl-804&label=harvest", &page)
+	assert.Len(t, page.Results, 1)
+
+	page = searchPage{}
+	get(t, s, "database/search?type=release&year=1973", &page)
+	assert.Empty(t, page.Results)
+
+	page = searchPage{}
+	get(t, s, "database/search?type=release&artist=Pink+Floyd&track=great+gig", &page)
+	assert.Len(t, page.Results, 1)
+	page = searchPage{}
+	get(t, s, "database/search?type=release&artist=Pink+Floyd&track=echoes", &page)
+	assert.Empty(t, page.Results)
+
+	page = searchPage{}
+	get(t, s, "database/search?type=artist&q=pink+floyd", &page)
+	require.Len(t, page.Results, 1)
+	assert.Equal(t, "Pink Floyd", page.Results[0].Title)
+
+	page = searchPage{}
+	get(t, s, "database/search?q=harvest&per_page=1&page=2", &page)
+	assert.Equal(t, 2, page.Pagination.Page)
+	assert.Equal(t, 2, page.Pagination.Pages) // релиз и лейбл
+	require.Len(t, page.Results, 1)
+	assert.Equal(t, "label", page.Results[0].Type)
+}
+
+func TestListsAndErrors(t *testing.T) {
+	s := newTestServer(t)
+
+	var versions struct {
+		Versions []struct {
+			ID int64 `json:"id"`
+		} `json:"versions"`
+	}
+	get(t, s, "masters/10362/versions?format=Vinyl&country=UK&released=1973", &versions)
+	require.Len(t, versions.Versions, 1)
+	assert.Equal(t, int64(1873013), versions.Versions[0].ID)
+
+	var releases struct {
+		Releases []json.RawMessage `json:"releases"`
+	}
+	get(t, s, "labels/2/releases?per_page=1", &releases)
+	assert.Len(t, releases.Releases, 1)
+
+	assert.Equal(t, http.StatusNotFound, get(t, s, "releases/1", nil).StatusCode)
+
+	s.FailNext(1, http.StatusTooManyRequests, 3)
+	resp := get(t, s, "releases/4139588", nil)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "3", resp.Header.Get("Retry-After"))
+	resp = get(t, s, "releases/4139588", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get(RateHeaderKey))
+	assert.Equal(t, "5", resp.Header.Get(RateUsedHeaderKey))
+	assert.Equal(t, "55", resp.Header.Get(RateRemainingHeaderKey))
+
+	req, _ := http.NewRequest(http.MethodGet, s.BaseURL()+"releases/4139588", nil)
+	req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	s.SetRateLimit(6)
+	assert.Equal(t, http.StatusTooManyRequests, get(t, s, "releases/4139588", nil).StatusCode)
+	assert.Len(t, s.Requests(), 7)
+}
@@ -0,0 +1,910 @@
+package discogs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	tp "github.com/ytsiuryn/go-stringutils"
+)
+
+// Dump entity kinds
+const (
+	DumpReleases = "releases"
+	DumpMasters  = "masters"
+	DumpArtists  = "artists"
+	DumpLabels   = "labels"
+)
+
+// Dump store constants
+const (
+	dumpManifestFile  = "manifest.json"
+	dumpImportPattern = ".import-*"
+	dumpSearchPerPage = 50
+	// Количество записей индекса, проверяемых при поиске.
+	dumpMaxScan = 10000
+)
+
+var dumpKinds = map[string]string{
+	"release": DumpReleases,
+	"master":  DumpMasters,
+	"artist":  DumpArtists,
+	"label":   DumpLabels,
+}
+
+// dumpEntry - поисковая запись сущности дампа.
+type dumpEntry struct {
+	ID       int32
+	Title    string
+	Artists  []string
+	Labels   []string
+	LabelIDs []int32
+	Catnos   []string
+	Barcodes []string
+	Formats  []string
+	Format   string
+	Country  string
+	Released string
+	Status   string
+	Thumb    string
+	Year     int32
+	MasterID int32
+}
+
+type dumpOffset struct {
+	Offset int64
+	Size   int
+}
+
+// dumpData - данные сущностей одного типа, импортированные из дампа. Хранятся в отдельном
+// каталоге (поколении) импорта:
+//
+//	docs.jsonl - документы Discogs API по одному на строку;
+//	ids        - таблица "ID\tсмещение\tразмер\tпоисковая запись" (ID дополнен нулями до 10 цифр);
+//	postings   - таблица поисковых индексов "ключ\tID" (см. dumpEntry.keys).
+type dumpData struct {
+	dir      string
+	docs     *os.File
+	ids      *dumpTable
+	postings *dumpTable
+}
+
+// DumpStore - локальное хранилище данных ежемесячных XML дампов Discogs.
+// Записи дампов хранятся в виде документов Discogs API, поисковые записи и индексы - в
+// отсортированных таблицах на диске (см. dumpTable), в памяти находятся только их разреженные
+// индексы. Импорт дампа выполняется в новый каталог, который заменяет данные того же типа
+// только после успешного завершения импорта; состав текущих каталогов хранится в файле
+// manifest.json.
+// Хранилище реализует интерфейс Backend, что позволяет работать без доступа к сети и без
+// ограничения частоты запросов.
+type DumpStore struct {
+	mu       sync.RWMutex
+	importMu sync.Mutex
+	dir      string
+	manifest map[string]string
+	data     map[string]*dumpData
+	// объем записей, сортируемых в памяти при импорте
+	sortBuffer int
+}
+
+// OpenDump открывает (или создает) локальное хранилище дампов в каталоге `dir`.
+// Каталоги прерванных импортов удаляются.
+func OpenDump(dir string) (*DumpStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &DumpStore{
+		dir:        dir,
+		manifest:   map[string]string{},
+		data:       map[string]*dumpData{},
+		sortBuffer: dumpSortBuffer,
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, dumpManifestFile))
+	if err == nil {
+		if err = json.Unmarshal(data, &s.manifest); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	for kind, name := range s.manifest {
+		if s.data[kind], err = openDumpData(filepath.Join(dir, name)); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	if stale, err := filepath.Glob(filepath.Join(dir, "*"+dumpImportPattern)); err == nil {
+		for _, path := range stale {
+			os.RemoveAll(path)
+		}
+	}
+	return s, nil
+}
+
+func openDumpData(dir string) (*dumpData, error) {
+	d := &dumpData{dir: dir}
+	var err error
+	if d.docs, err = os.Open(filepath.Join(dir, "docs.jsonl")); err != nil {
+		return nil, err
+	}
+	if d.ids, err = openDumpTable(filepath.Join(dir, "ids")); err != nil {
+		d.Close()
+		return nil, err
+	}
+	if d.postings, err = openDumpTable(filepath.Join(dir, "postings")); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *dumpData) Close() error {
+	err := d.docs.Close()
+	for _, t := range []*dumpTable{d.ids, d.postings} {
+		if t == nil {
+			continue
+		}
+		if closeErr := t.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Close закрывает файлы хранилища.
+func (s *DumpStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for kind, d := range s.data {
+		if closeErr := d.Close(); closeErr != nil {
+			err = closeErr
+		}
+		delete(s.data, kind)
+	}
+	return err
+}
+
+// ImportFile импортирует файл дампа (XML или XML.gz).
+func (s *DumpStore) ImportFile(path string) (string, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	return s.Import(f)
+}
+
+// Import импортирует дамп (XML или XML.gz) и возвращает тип сущностей дампа и количество
+// записей. Тип определяется по корневому элементу. Ранее импортированные данные того же
+// типа заменяются после успешного завершения импорта и до этого остаются доступными;
+// при ошибке импорта они не изменяются.
+func (s *DumpStore) Import(r io.Reader) (kind string, n int, err error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return "", 0, err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+	dec := xml.NewDecoder(br)
+	root, err := dumpRoot(dec)
+	if err != nil {
+		return "", 0, err
+	}
+	kind = root.Name.Local
+	elemName := strings.TrimSuffix(kind, "s")
+	if dumpKinds[elemName] != kind {
+		return "", 0, fmt.Errorf("unknown dump type: %s", kind)
+	}
+
+	s.importMu.Lock()
+	defer s.importMu.Unlock()
+	tmp, err := ioutil.TempDir(s.dir, kind+dumpImportPattern)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(tmp)
+		}
+	}()
+	if n, err = s.build(dec, elemName, kind, tmp); err != nil {
+		return "", 0, err
+	}
+	name := kind + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err = os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		return "", 0, err
+	}
+	tmp = filepath.Join(s.dir, name)
+	data, err := openDumpData(tmp)
+	if err != nil {
+		return "", 0, err
+	}
+
+	s.mu.Lock()
+	manifest := map[string]string{kind: name}
+	for k, v := range s.manifest {
+		if k != kind {
+			manifest[k] = v
+		}
+	}
+	if err = s.saveManifest(manifest); err != nil {
+		s.mu.Unlock()
+		data.Close()
+		return "", 0, err
+	}
+	old := s.data[kind]
+	s.data[kind], s.manifest = data, manifest
+	s.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+		os.RemoveAll(old.dir)
+	}
+	return kind, n, nil
+}
+
+// Запись документов, поисковых записей и индексов дампа в каталог `dir`.
+func (s *DumpStore) build(dec *xml.Decoder, elemName, kind, dir string) (int, error) {
+	out, err := os.Create(filepath.Join(dir, "docs.jsonl"))
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+	ids := newDumpSorter(dir, "ids", s.sortBuffer)
+	postings := newDumpSorter(dir, "postings", s.sortBuffer)
+	var offset int64
+	n := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != elemName {
+			if err = dec.Skip(); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		id, doc, entry, err := decodeDumpElement(dec, &start, kind)
+		if err != nil {
+			return 0, err
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return 0, err
+		}
+		record, err := json.Marshal(entry)
+		if err != nil {
+			return 0, err
+		}
+		if _, err = w.Write(append(data, '\n')); err != nil {
+			return 0, err
+		}
+		key := dumpKey(id)
+		err = ids.add(key, fmt.Sprintf("%d\t%d\t%s", offset, len(data), record))
+		if err != nil {
+			return 0, err
+		}
+		for _, k := range entry.keys(kind) {
+			if err = postings.add(k, key); err != nil {
+				return 0, err
+			}
+		}
+		offset += int64(len(data)) + 1
+		n++
+	}
+	if err = w.Flush(); err != nil {
+		return 0, err
+	}
+	if err = out.Sync(); err != nil {
+		return 0, err
+	}
+	if err = ids.write(filepath.Join(dir, "ids")); err != nil {
+		return 0, err
+	}
+	if err = postings.write(filepath.Join(dir, "postings")); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Search выполняет поиск по параметрам запроса Discogs API "database/search".
+func (s *DumpStore) Search(params url.Values) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	resp, err := s.search(params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resp)
+}
+
+// Release возвращает документ релиза.
+func (s *DumpStore) Release(id string) ([]byte, error) {
+	return s.get(DumpReleases, id)
+}
+
+// Master возвращает документ мастер-релиза.
+func (s *DumpStore) Master(id string) ([]byte, error) {
+	n, err := dumpID(id)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.master(n)
+}
+
+// Versions возвращает страницу списка версий мастер-релиза.
+func (s *DumpStore) Versions(masterID string, params url.Values) ([]byte, error) {
+	n, err := dumpID(masterID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	resp, err := s.masterVersions(n, params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resp)
+}
+
+// Artist возвращает документ исполнителя.
+func (s *DumpStore) Artist(id string) ([]byte, error) {
+	return s.get(DumpArtists, id)
+}
+
+// Label возвращает документ лейбла.
+func (s *DumpStore) Label(id string) ([]byte, error) {
+	return s.get(DumpLabels, id)
+}
+
+// LabelReleases возвращает страницу списка релизов лейбла.
+func (s *DumpStore) LabelReleases(labelID string, params url.Values) ([]byte, error) {
+	n, err := dumpID(labelID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	resp, err := s.labelReleasesPage(n, params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resp)
+}
+
+func (s *DumpStore) get(kind, id string) ([]byte, error) {
+	n, err := dumpID(id)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.document(kind, n)
+}
+
+func (s *DumpStore) document(kind string, id int32) ([]byte, error) {
+	var off dumpOffset
+	ok := false
+	d := s.data[kind]
+	if d != nil {
+		var err error
+		if _, off, ok, err = d.entry(dumpKey(id)); err != nil {
+			return nil, err
+		}
+	}
+	if !ok {
+		return nil, &StatusError{
+			URL:        BaseURL + kind + "/" + strconv.Itoa(int(id)),
+			StatusCode: http.StatusNotFound,
+			Message:    "Resource not found.",
+		}
+	}
+	data := make([]byte, off.Size)
+	if _, err := d.docs.ReadAt(data, off.Offset); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Поисковая запись и расположение документа сущности по ключу ID (см. dumpKey).
+func (d *dumpData) entry(key string) (*dumpEntry, dumpOffset, bool, error) {
+	var off dumpOffset
+	value, ok, err := d.ids.first(key)
+	if err != nil || !ok {
+		return nil, off, false, err
+	}
+	fields := strings.SplitN(value, "\t", 3)
+	if len(fields) != 3 {
+		return nil, off, false, fmt.Errorf("dump index is corrupted: %s", d.dir)
+	}
+	if off.Offset, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+		return nil, off, false, err
+	}
+	if off.Size, err = strconv.Atoi(fields[1]); err != nil {
+		return nil, off, false, err
+	}
+	var e dumpEntry
+	if err = json.Unmarshal([]byte(fields[2]), &e); err != nil {
+		return nil, off, false, err
+	}
+	return &e, off, true, nil
+}
+
+// Поисковые записи сущностей, ID которых указаны в индексе под ключом `key`, начиная
+// с `skip`-й записи. Передача записей прекращается, когда `fn` возвращает false.
+func (d *dumpData) entries(key string, skip int, fn func(*dumpEntry) bool) error {
+	var lookupErr error
+	err := d.postings.scan(key, func(id string) bool {
+		if skip > 0 {
+			skip--
+			return true
+		}
+		e, _, ok, err := d.entry(id)
+		if err != nil {
+			lookupErr = err
+			return false
+		}
+		return !ok || fn(e)
+	})
+	if err != nil {
+		return err
+	}
+	return lookupErr
+}
+
+// Документ мастер-релиза дополняется сведениями, отсутствующими в дампе мастер-релизов:
+// списком треков основной версии и ID последней версии.
+func (s *DumpStore) master(id int32) ([]byte, error) {
+	data, err := s.document(DumpMasters, id)
+	if err != nil {
+		return nil, err
+	}
+	var mi masterInfo
+	if err = json.Unmarshal(data, &mi); err != nil {
+		return nil, err
+	}
+	if main, err := s.document(DumpReleases, mi.MainRelease); err == nil {
+		var ri releaseInfo
+		if json.Unmarshal(main, &ri) == nil {
+			mi.Tracklist = ri.Tracklist
+		}
+	}
+	var recent *dumpEntry
+	if releases := s.data[DumpReleases]; releases != nil {
+		err = releases.entries("m:"+dumpKey(id), 0, func(e *dumpEntry) bool {
+			if recent == nil || e.Released > recent.Released {
+				recent = e
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if recent != nil {
+		mi.MostRecentRelease = recent.ID
+		mi.MostRecentReleaseURL = BaseURL + "releases/" + strconv.Itoa(int(recent.ID))
+	}
+	return json.Marshal(&mi)
+}
+
+// Поиск по параметрам запроса "database/search": q, type, title, release_title, artist,
+// label, catno, barcode, year, country, format. Проверяется не более dumpMaxScan записей
+// индекса по штрих-коду, номеру в каталоге или самому редкому из терминов запроса.
+func (s *DumpStore) search(query url.Values) (*searchResponse, error) {
+	kind := dumpKinds[query.Get("type")]
+	if kind == "" {
+		kind = DumpReleases
+	}
+	resp := &searchResponse{}
+	d := s.data[kind]
+	if d == nil {
+		return resp, nil
+	}
+	catno := normalizeCatno(query.Get("catno"))
+	title := query.Get("title")
+	if title == "" {
+		title = query.Get("release_title")
+	}
+	terms := dumpTokens(query.Get("q") + " " + title + " " + strings.Join(query["artist"], " "))
+	var key string
+	switch {
+	case query.Get("barcode") != "":
+		barcode, ok := NormalizeBarcode(query.Get("barcode"))
+		if !ok {
+			return resp, nil
+		}
+		key = "b:" + barcode
+	case catno != "":
+		key = "c:" + catno
+	case len(terms) > 0:
+		var err error
+		if key, err = d.rarest(terms); err != nil {
+			return nil, err
+		}
+	default:
+		return resp, nil
+	}
+
+	scanned := 0
+	err := d.entries(key, 0, func(e *dumpEntry) bool {
+		scanned++
+		if e.match(query, title, kind) {
+			resp.Results = append(resp.Results, e.searchResult(kind))
+		}
+		return len(resp.Results) < dumpSearchPerPage && scanned < dumpMaxScan
+	})
+	return resp, err
+}
+
+// Ключ индекса самого редкого из терминов запроса. Записи терминов подсчитываются не далее
+// количества записей самого редкого из уже просмотренных терминов.
+func (d *dumpData) rarest(terms []string) (string, error) {
+	var best string
+	limit := dumpMaxScan
+	for _, term := range terms {
+		key := "t:" + term
+		n, err := d.postings.count(key, limit)
+		if err != nil {
+			return "", err
+		}
+		if best == "" || n < limit {
+			best, limit = key, n
+		}
+		if n == 0 {
+			break
+		}
+	}
+	return best, nil
+}
+
+func (s *DumpStore) masterVersions(id int32, query url.Values) (*versionsResponse, error) {
+	filter := &VersionFilter{
+		Format:  query.Get("format"),
+		Country: query.Get("country"),
+		Year:    tp.NaiveStringToInt(query.Get("released")),
+	}
+	var versions []versionInfo
+	if d := s.data[DumpReleases]; d != nil {
+		err := d.entries("m:"+dumpKey(id), 0, func(e *dumpEntry) bool {
+			if vi := e.versionInfo(); filter.Match(vi.Version()) {
+				versions = append(versions, vi)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp := &versionsResponse{}
+	start, end := dumpPage(query, len(versions), &resp.Pagination)
+	resp.Versions = versions[start:end]
+	return resp, nil
+}
+
+func (s *DumpStore) labelReleasesPage(id int32, query url.Values) (*labelReleasesResponse, error) {
+	resp := &labelReleasesResponse{}
+	d := s.data[DumpReleases]
+	if d == nil {
+		dumpPage(query, 0, &resp.Pagination)
+		return resp, nil
+	}
+	key := "l:" + dumpKey(id)
+	total, err := d.postings.count(key, 0)
+	if err != nil {
+		return nil, err
+	}
+	start, end := dumpPage(query, total, &resp.Pagination)
+	if start == end {
+		return resp, nil
+	}
+	err = d.entries(key, start, func(e *dumpEntry) bool {
+		resp.Releases = append(resp.Releases, e.labelReleaseInfo(id))
+		return len(resp.Releases) < end-start
+	})
+	return resp, err
+}
+
+func (s *DumpStore) saveManifest(manifest map[string]string) error {
+	path := filepath.Join(s.dir, dumpManifestFile)
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Ключ ID сущности в таблицах: ID, дополненный нулями до 10 цифр, упорядочивается как число.
+func dumpKey(id int32) string {
+	return fmt.Sprintf("%010d", id)
+}
+
+// Ключи индекса записи: термины ("t:"), а для релизов также штрих-коды ("b:"), номера
+// в каталоге ("c:"), ID мастер-релиза ("m:") и лейблов ("l:").
+func (e *dumpEntry) keys(kind string) []string {
+	var keys []string
+	for _, token := range e.tokens() {
+		keys = append(keys, "t:"+token)
+	}
+	if kind != DumpReleases {
+		return keys
+	}
+	for _, b := range e.Barcodes {
+		if barcode, ok := NormalizeBarcode(b); ok {
+			keys = append(keys, "b:"+barcode)
+		}
+	}
+	for i, catno := range e.Catnos {
+		if catno = normalizeCatno(catno); catno != "" {
+			keys = append(keys, "c:"+catno)
+		}
+		keys = append(keys, "l:"+dumpKey(e.LabelIDs[i]))
+	}
+	if e.MasterID != 0 {
+		keys = append(keys, "m:"+dumpKey(e.MasterID))
+	}
+	return keys
+}
+
+// Уникальные термины наименования, исполнителей и лейблов записи.
+func (e *dumpEntry) tokens() []string {
+	names := []string{e.Title}
+	for _, name := range append(e.Artists, e.Labels...) {
+		names = append(names, trimNameIndex(name))
+	}
+	known := map[string]bool{}
+	var ret []string
+	for _, token := range dumpTokens(strings.Join(names, " ")) {
+		if !known[token] {
+			known[token] = true
+			ret = append(ret, token)
+		}
+	}
+	return ret
+}
+
+func (e *dumpEntry) match(query url.Values, title, kind string) bool {
+	if !dumpContains([]string{e.Title}, title) ||
+		!dumpContains(append(append([]string{e.Title}, e.Artists...), e.Labels...), query.Get("q")) {
+		return false
+	}
+	for _, name := range query["artist"] {
+		if !dumpContains(e.Artists, name) {
+			return false
+		}
+	}
+	if lbl := query.Get("label"); lbl != "" && !dumpContains(e.Labels, lbl) {
+		return false
+	}
+	if catno := normalizeCatno(query.Get("catno")); catno != "" {
+		found := false
+		for _, c := range e.Catnos {
+			found = found || normalizeCatno(c) == catno
+		}
+		if !found {
+			return false
+		}
+	}
+	if year := query.Get("year"); year != "" && year != strconv.Itoa(int(e.Year)) {
+		return false
+	}
+	if country := query.Get("country"); country != "" && !strings.EqualFold(country, e.Country) {
+		return false
+	}
+	if f := query.Get("format"); f != "" && !strings.Contains(strings.ToLower(e.Format), strings.ToLower(f)) {
+		return false
+	}
+	return true
+}
+
+func (e *dumpEntry) searchResult(kind string) searchResult {
+	id := strconv.Itoa(int(e.ID))
+	result := searchResult{
+		ID:          e.ID,
+		Type:        strings.TrimSuffix(kind, "s"),
+		Title:       e.Title,
+		Thumb:       e.Thumb,
+		Country:     e.Country,
+		Format:      e.Formats,
+		Label:       e.Labels,
+		Barcode:     e.Barcodes,
+		MasterID:    e.MasterID,
+		ResourceURL: BaseURL + kind + "/" + id,
+	}
+	if len(e.Artists) > 0 {
+		result.Title = strings.Join(e.Artists, ", ") + " - " + e.Title
+	}
+	if e.Year != 0 {
+		result.Year = strconv.Itoa(int(e.Year))
+	}
+	if len(e.Catnos) > 0 {
+		result.CatNo = e.Catnos[0]
+	}
+	if e.MasterID != 0 {
+		result.MasterURL = BaseURL + "masters/" + strconv.Itoa(int(e.MasterID))
+	}
+	return result
+}
+
+func (e *dumpEntry) versionInfo() versionInfo {
+	vi := versionInfo{
+		ID:           e.ID,
+		Title:        e.Title,
+		Country:      e.Country,
+		Format:       e.Format,
+		MajorFormats: e.Formats,
+		Status:       e.Status,
+		Thumb:        e.Thumb,
+		ResourceURL:  BaseURL + "releases/" + strconv.Itoa(int(e.ID)),
+	}
+	if e.Year != 0 {
+		vi.Released = strconv.Itoa(int(e.Year))
+	}
+	if len(e.Labels) > 0 {
+		vi.Label, vi.Catno = e.Labels[0], e.Catnos[0]
+	}
+	return vi
+}
+
+func (e *dumpEntry) labelReleaseInfo(labelID int32) labelReleaseInfo {
+	lri := labelReleaseInfo{
+		ID:          e.ID,
+		Status:      e.Status,
+		Format:      e.Format,
+		Thumb:       e.Thumb,
+		Title:       e.Title,
+		Year:        e.Year,
+		Artist:      strings.Join(e.Artists, ", "),
+		ResourceURL: BaseURL + "releases/" + strconv.Itoa(int(e.ID)),
+	}
+	for i, id := range e.LabelIDs {
+		if id == labelID {
+			lri.Catno = e.Catnos[i]
+			break
+		}
+	}
+	return lri
+}
+
+func dumpID(id string) (int32, error) {
+	n, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid Discogs ID %q", ErrInvalidRequest, id)
+	}
+	return int32(n), nil
+}
+
+// Первый элемент дампа.
+func dumpRoot(dec *xml.Decoder) (*xml.StartElement, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return &start, nil
+		}
+	}
+}
+
+func decodeDumpElement(
+	dec *xml.Decoder, start *xml.StartElement, kind string) (int32, interface{}, *dumpEntry, error) {
+	switch kind {
+	case DumpReleases:
+		var x xmlRelease
+		if err := dec.DecodeElement(&x, start); err != nil {
+			return 0, nil, nil, err
+		}
+		return x.ID, x.info(), x.entry(), nil
+	case DumpMasters:
+		var x xmlMaster
+		if err := dec.DecodeElement(&x, start); err != nil {
+			return 0, nil, nil, err
+		}
+		return x.ID, x.info(), x.entry(), nil
+	case DumpArtists:
+		var x xmlArtistProfile
+		if err := dec.DecodeElement(&x, start); err != nil {
+			return 0, nil, nil, err
+		}
+		return x.ID, x.info(), &dumpEntry{ID: x.ID, Title: x.Name, Thumb: xmlThumb(x.Images)}, nil
+	default:
+		var x xmlLabelProfile
+		if err := dec.DecodeElement(&x, start); err != nil {
+			return 0, nil, nil, err
+		}
+		return x.ID, x.info(), &dumpEntry{ID: x.ID, Title: x.Name, Thumb: xmlThumb(x.Images)}, nil
+	}
+}
+
+// Страница списка из `total` элементов по параметрам запроса page и per_page.
+func dumpPage(query url.Values, total int, p *pagination) (start, end int) {
+	p.Page, p.PerPage, p.Items = 1, dumpSearchPerPage, total
+	if page, err := strconv.Atoi(query.Get("page")); err == nil && page > 0 {
+		p.Page = page
+	}
+	if perPage, err := strconv.Atoi(query.Get("per_page")); err == nil && perPage > 0 {
+		if perPage > MaxPageSize {
+			perPage = MaxPageSize
+		}
+		p.PerPage = perPage
+	}
+	p.Pages = (total + p.PerPage - 1) / p.PerPage
+	start = (p.Page - 1) * p.PerPage
+	if start > total {
+		start = total
+	}
+	end = start + p.PerPage
+	if end > total {
+		end = total
+	}
+	return
+}
+
+// Термины текста: слова в нижнем регистре.
+func dumpTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(ch rune) bool {
+		return !unicode.IsLetter(ch) && !unicode.IsDigit(ch)
+	})
+}
+
+// Проверка вхождения всех терминов `text` в термины значений (без номеров омонимов Discogs).
+func dumpContains(values []string, text string) bool {
+	terms := dumpTokens(text)
+	if len(terms) == 0 {
+		return true
+	}
+	known := map[string]bool{}
+	for _, v := range values {
+		for _, token := range dumpTokens(trimNameIndex(v)) {
+			known[token] = true
+		}
+	}
+	for _, term := range terms {
+		if !known[term] {
+			return false
+		}
+	}
+	return true
+}
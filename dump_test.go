@@ -0,0 +1,238 @@
+package discogs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+func newTestDump(t *testing.T) *DumpStore {
+	dir := t.TempDir()
+	store, err := OpenDump(dir)
+	require.NoError(t, err)
+	for _, kind := range []string{DumpReleases, DumpMasters, DumpArtists, DumpLabels} {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", "dump", kind+".xml"))
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(data)
+		require.NoError(t, gz.Close())
+		imported, n, err := store.Import(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, kind, imported)
+		assert.NotZero(t, n)
+	}
+	require.NoError(t, store.Close())
+	// индекс хранилища сохраняется между запусками
+	store, err = OpenDump(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestDumpRelease(t *testing.T) {
+	d := New("", "", WithDump(newTestDump(t)))
+
+	r := md.NewRelease()
+	require.NoError(t, d.releaseByID("1", r))
+	assert.Equal(t, "Stockholm", r.Title)
+	assert.Equal(t, 1999, r.Year)
+	assert.Equal(t, 1999, r.Original.Year)
+	assert.Equal(t, "5427", r.Original.IDs[md.DiscogsMasterID])
+	assert.Len(t, r.Tracks, 6)
+	assert.Equal(t, "7 332331 000323", r.Publishing.IDs[md.PublishingBarcode])
+	assert.Equal(t, "SK032", r.Publishing.Labels[0].Catno)
+
+	_, err := d.backend.Release("100")
+	require.IsType(t, &StatusError{}, err)
+}
+
+func TestDumpSearch(t *testing.T) {
+	d := New("", "", WithDump(newTestDump(t)))
+
+	r := md.NewRelease()
+	r.Publishing.IDs[md.PublishingBarcode] = "7332331000323"
+	set, err := d.searchReleaseByIdentifiers(r, &d.search)
+	require.NoError(t, err)
+	require.Len(t, set.Suggestions, 1)
+	assert.Equal(t, "1", set.Suggestions[0].Release.IDs[md.DiscogsReleaseID])
+
+	r = md.NewRelease()
+	r.Publishing.Labels = append(r.Publishing.Labels, &md.Label{Label: "Svek", Catno: "SK-026"})
+	set, err = d.searchReleaseByIdentifiers(r, &d.search)
+	require.NoError(t, err)
+	require.Len(t, set.Suggestions, 1)
+	assert.Equal(t, "3", set.Suggestions[0].Release.IDs[md.DiscogsReleaseID])
+
+	r = md.NewRelease()
+	r.Title = "Stockholm"
+	r.ActorRoles.Add("The Persuader", "performer")
+	set, err = d.searchReleaseByIncompleteData(r, &d.search, nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, set.Suggestions)
+
+	artists, err := d.searchArtistByName("The Persuader")
+	require.NoError(t, err)
+	require.Len(t, artists, 1)
+	assert.Equal(t, "Jesper Dahlbäck", artists[0].Artist.RealName)
+}
+
+func TestDumpMasterAndLabel(t *testing.T) {
+	d := New("", "", WithDump(newTestDump(t)))
+
+	r := md.NewRelease()
+	r.IDs[md.DiscogsMasterID] = "5427"
+	data, err := d.master(&AudioOnlineRequest{
+		Cmd: "master", Release: r, Versions: &VersionFilter{Country: "germany"}})
+	require.NoError(t, err)
+	var resp AudioOnlineResponse
+	require.NoError(t, json.Unmarshal(data, &resp))
+	assert.Equal(t, "1", resp.Master.MainReleaseID)
+	assert.Equal(t, "2", resp.Master.MostRecentReleaseID)
+	assert.Len(t, resp.Master.Release.Tracks, 6)
+	require.Len(t, resp.Master.Versions, 1)
+	assert.Equal(t, "SK032CD", resp.Master.Versions[0].Catno)
+
+	data, err = d.label(&AudioOnlineRequest{
+		Cmd: "label", Label: &md.Label{Label: "Svek"}, Pagination: &Pagination{Page: 1, PerPage: 2}})
+	require.NoError(t, err)
+	resp = AudioOnlineResponse{}
+	require.NoError(t, json.Unmarshal(data, &resp))
+	require.NotEmpty(t, resp.Labels)
+	lbl := resp.Labels[0].Label
+	assert.Equal(t, "5", lbl.IDs[md.DiscogsLabelID])
+	assert.Len(t, lbl.Releases, 2)
+	assert.Equal(t, 3, lbl.Pagination.Items)
+}
+
+// Дамп релизов `n` синтетических релизов: по 10 версий мастер-релиза, 7 лейблов.
+func syntheticReleasesDump(n int) *bytes.Buffer {
+	var buf bytes.Buffer
+	buf.WriteString("<releases>\n")
+	for id := 1; id <= n; id++ {
+		fmt.Fprintf(&buf, `<release id="%d" status="Accepted">`+
+			`<artists><artist><id>%d</id><name>Artist %d</name></artist></artists>`+
+			`<title>Synthetic Title %d</title>`+
+			`<labels><label name="Label %d" catno="SYN-%05d" id="%d"/></labels>`+
+			`<formats><format name="Vinyl" qty="1" text=""></format></formats>`+
+			`<country>UK</country><released>%d</released>`+
+			`<master_id is_main_release="%t">%d</master_id>`+
+			`<tracklist><track><position>A</position><title>Track %d</title></track></tracklist>`+
+			"</release>\n",
+			id, id%50+1, id%50+1, id, id%7+1, id, id%7+1, 1960+id%40, id%10 == 1, (id-1)/10+1, id)
+	}
+	buf.WriteString("</releases>\n")
+	return &buf
+}
+
+func TestDumpLarge(t *testing.T) {
+	const n = 5000
+	dir := t.TempDir()
+	store, err := OpenDump(dir)
+	require.NoError(t, err)
+	// сортировка индексов частями и их слияние
+	store.sortBuffer = 16 << 10
+	kind, imported, err := store.Import(syntheticReleasesDump(n))
+	require.NoError(t, err)
+	assert.Equal(t, DumpReleases, kind)
+	assert.Equal(t, n, imported)
+	require.NoError(t, store.Close())
+
+	store, err = OpenDump(dir)
+	require.NoError(t, err)
+	defer store.Close()
+	// в памяти находятся только разреженные индексы таблиц
+	releases := store.data[DumpReleases]
+	assert.Less(t, len(releases.ids.index.Keys), n/20)
+	assert.Less(t, len(releases.postings.index.Keys), n/20)
+
+	data, err := store.Release("4321")
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Synthetic Title 4321")
+
+	var resp searchResponse
+	data, err = store.Search(url.Values{"catno": {"SYN 04321"}})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &resp))
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, int32(4321), resp.Results[0].ID)
+
+	resp = searchResponse{}
+	data, err = store.Search(url.Values{"title": {"Synthetic Title 2500"}, "artist": {"Artist 1"}})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &resp))
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, int32(2500), resp.Results[0].ID)
+
+	var versions versionsResponse
+	data, err = store.Versions("433", url.Values{"per_page": {"100"}})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &versions))
+	assert.Len(t, versions.Versions, 10)
+
+	var lr labelReleasesResponse
+	data, err = store.LabelReleases("3", url.Values{"page": {"3"}, "per_page": {"100"}})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &lr))
+	assert.Equal(t, (n-2)/7+1, lr.Pagination.Items)
+	require.Len(t, lr.Releases, 100)
+	// релизы лейбла упорядочены по ID: 2, 9, 16, ...
+	assert.Equal(t, int32(2+200*7), lr.Releases[0].ID)
+}
+
+func TestDumpImportFailure(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenDump(dir)
+	require.NoError(t, err)
+	_, _, err = store.Import(syntheticReleasesDump(10))
+	require.NoError(t, err)
+
+	// прерванный дамп не заменяет импортированные ранее данные
+	broken := syntheticReleasesDump(20).Bytes()
+	_, _, err = store.Import(bytes.NewReader(broken[:len(broken)/2]))
+	require.Error(t, err)
+	_, err = store.Release("20")
+	require.IsType(t, &StatusError{}, err)
+	_, err = store.Release("5")
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	store, err = OpenDump(dir)
+	require.NoError(t, err)
+	defer store.Close()
+	_, err = store.Release("5")
+	require.NoError(t, err)
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, fi := range files {
+		names = append(names, fi.Name())
+	}
+	assert.Len(t, names, 2, names)
+	assert.Contains(t, names, dumpManifestFile)
+}
+
+func BenchmarkDumpSearch(b *testing.B) {
+	store, err := OpenDump(b.TempDir())
+	require.NoError(b, err)
+	defer store.Close()
+	_, _, err = store.Import(syntheticReleasesDump(20000))
+	require.NoError(b, err)
+	query := url.Values{"title": {"Synthetic Title 12345"}, "artist": {"Artist 46"}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.Search(query); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package discogs
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dump table constants
+const (
+	// Объем записей таблицы (в байтах) между соседними ключами разреженного индекса.
+	dumpBlockSize = 32 << 10
+	// Объем записей (в байтах), сортируемых в памяти при построении таблицы.
+	dumpSortBuffer = 64 << 20
+)
+
+// dumpTable - файл записей "ключ\tзначение", отсортированных по ключу и значению.
+// В памяти хранится только разреженный индекс таблицы: первый ключ и смещение каждого
+// блока записей размером около dumpBlockSize байт; записи читаются с диска при поиске.
+type dumpTable struct {
+	f     *os.File
+	size  int64
+	index dumpTableIndex
+}
+
+// dumpTableIndex - разреженный индекс таблицы, сохраняемый в файле "<таблица>.idx".
+type dumpTableIndex struct {
+	Keys    []string
+	Offsets []int64
+}
+
+func openDumpTable(path string) (*dumpTable, error) {
+	t := &dumpTable{}
+	idx, err := os.Open(path + ".idx")
+	if err != nil {
+		return nil, err
+	}
+	err = gob.NewDecoder(idx).Decode(&t.index)
+	idx.Close()
+	if err != nil {
+		return nil, err
+	}
+	if t.f, err = os.Open(path); err != nil {
+		return nil, err
+	}
+	fi, err := t.f.Stat()
+	if err != nil {
+		t.f.Close()
+		return nil, err
+	}
+	t.size = fi.Size()
+	return t, nil
+}
+
+func (t *dumpTable) Close() error {
+	return t.f.Close()
+}
+
+// scan передает `fn` значения записей с ключом `key` в порядке их следования, пока `fn`
+// возвращает true.
+func (t *dumpTable) scan(key string, fn func(value string) bool) error {
+	if len(t.index.Keys) == 0 {
+		return nil
+	}
+	// записи ключа могут начинаться в блоке, предшествующем первому блоку с ключом не меньше `key`
+	i := sort.SearchStrings(t.index.Keys, key)
+	if i > 0 {
+		i--
+	}
+	offset := t.index.Offsets[i]
+	r := bufio.NewReader(io.NewSectionReader(t.f, offset, t.size-offset))
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		k, v := splitDumpRecord(line[:len(line)-1])
+		if k < key {
+			continue
+		}
+		if k > key || !fn(v) {
+			return nil
+		}
+	}
+}
+
+// first возвращает значение первой записи с ключом `key`.
+func (t *dumpTable) first(key string) (value string, ok bool, err error) {
+	err = t.scan(key, func(v string) bool {
+		value, ok = v, true
+		return false
+	})
+	return
+}
+
+// count возвращает количество записей с ключом `key`, но не более `limit` (0 - без ограничения).
+func (t *dumpTable) count(key string, limit int) (n int, err error) {
+	err = t.scan(key, func(string) bool {
+		n++
+		return limit == 0 || n < limit
+	})
+	return
+}
+
+func splitDumpRecord(line string) (key, value string) {
+	if i := strings.IndexByte(line, '\t'); i >= 0 {
+		return line[:i], line[i+1:]
+	}
+	return line, ""
+}
+
+// dumpTableWriter записывает отсортированные записи таблицы и ее разреженный индекс.
+type dumpTableWriter struct {
+	path   string
+	f      *os.File
+	w      *bufio.Writer
+	offset int64
+	index  dumpTableIndex
+}
+
+func createDumpTable(path string) (*dumpTableWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &dumpTableWriter{path: path, f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// add добавляет запись "ключ\tзначение" (без завершающего перевода строки).
+func (tw *dumpTableWriter) add(record string) error {
+	if n := len(tw.index.Offsets); n == 0 || tw.offset-tw.index.Offsets[n-1] >= dumpBlockSize {
+		key, _ := splitDumpRecord(record)
+		tw.index.Keys = append(tw.index.Keys, key)
+		tw.index.Offsets = append(tw.index.Offsets, tw.offset)
+	}
+	n, err := tw.w.WriteString(record + "\n")
+	tw.offset += int64(n)
+	return err
+}
+
+func (tw *dumpTableWriter) close() error {
+	err := tw.w.Flush()
+	if err == nil {
+		err = tw.f.Sync()
+	}
+	if closeErr := tw.f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	idx, err := os.Create(tw.path + ".idx")
+	if err != nil {
+		return err
+	}
+	if err = gob.NewEncoder(idx).Encode(&tw.index); err != nil {
+		idx.Close()
+		return err
+	}
+	if err = idx.Sync(); err != nil {
+		idx.Close()
+		return err
+	}
+	return idx.Close()
+}
+
+// dumpSorter строит таблицу из записей в произвольном порядке, объем которых может превышать
+// доступную память: записи сортируются в памяти частями не более `limit` байт, части
+// сохраняются во временные файлы каталога `dir` и затем сливаются в таблицу.
+type dumpSorter struct {
+	dir     string
+	name    string
+	limit   int
+	size    int
+	records []string
+	runs    []string
+}
+
+func newDumpSorter(dir, name string, limit int) *dumpSorter {
+	return &dumpSorter{dir: dir, name: name, limit: limit}
+}
+
+func (s *dumpSorter) add(key, value string) error {
+	record := key + "\t" + value
+	s.records = append(s.records, record)
+	s.size += len(record)
+	if s.size >= s.limit {
+		return s.flush()
+	}
+	return nil
+}
+
+// Сохранение отсортированной части записей во временный файл.
+func (s *dumpSorter) flush() error {
+	sort.Strings(s.records)
+	path := filepath.Join(s.dir, s.name+".run"+strconv.Itoa(len(s.runs)))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, record := range s.records {
+		if _, err = w.WriteString(record + "\n"); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	s.runs = append(s.runs, path)
+	s.records, s.size = nil, 0
+	return nil
+}
+
+// write записывает таблицу в файл `path`. Повторяющиеся записи исключаются.
+func (s *dumpSorter) write(path string) error {
+	tw, err := createDumpTable(path)
+	if err != nil {
+		return err
+	}
+	if len(s.runs) == 0 {
+		sort.Strings(s.records)
+		err = writeUnique(tw, func() (string, bool, error) {
+			if len(s.records) == 0 {
+				return "", false, nil
+			}
+			record := s.records[0]
+			s.records = s.records[1:]
+			return record, true, nil
+		})
+	} else if err = s.flush(); err == nil {
+		err = s.merge(tw)
+	}
+	if closeErr := tw.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Слияние отсортированных частей.
+func (s *dumpSorter) merge(tw *dumpTableWriter) error {
+	h := &dumpRunHeap{}
+	for _, path := range s.runs {
+		f, err := os.Open(path)
+		if err != nil {
+			h.close()
+			return err
+		}
+		run := &dumpRun{f: f, r: bufio.NewReader(f)}
+		if err = run.next(); err == io.EOF {
+			f.Close()
+			continue
+		} else if err != nil {
+			f.Close()
+			h.close()
+			return err
+		}
+		h.runs = append(h.runs, run)
+	}
+	heap.Init(h)
+	defer h.close()
+	return writeUnique(tw, func() (string, bool, error) {
+		if h.Len() == 0 {
+			return "", false, nil
+		}
+		run := h.runs[0]
+		record := run.record
+		if err := run.next(); err == io.EOF {
+			run.f.Close()
+			heap.Pop(h)
+		} else if err != nil {
+			return "", false, err
+		} else {
+			heap.Fix(h, 0)
+		}
+		return record, true, nil
+	})
+}
+
+// Запись в таблицу отсортированной последовательности записей без повторов.
+func writeUnique(tw *dumpTableWriter, next func() (string, bool, error)) error {
+	var last string
+	for first := true; ; first = false {
+		record, ok, err := next()
+		if err != nil || !ok {
+			return err
+		}
+		if !first && record == last {
+			continue
+		}
+		if err = tw.add(record); err != nil {
+			return err
+		}
+		last = record
+	}
+}
+
+// dumpRun - чтение отсортированной части записей.
+type dumpRun struct {
+	f      *os.File
+	r      *bufio.Reader
+	record string
+}
+
+func (run *dumpRun) next() error {
+	line, err := run.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	run.record = line[:len(line)-1]
+	return nil
+}
+
+type dumpRunHeap struct {
+	runs []*dumpRun
+}
+
+func (h *dumpRunHeap) Len() int           { return len(h.runs) }
+func (h *dumpRunHeap) Less(i, j int) bool { return h.runs[i].record < h.runs[j].record }
+func (h *dumpRunHeap) Swap(i, j int)      { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+func (h *dumpRunHeap) Push(x interface{}) { h.runs = append(h.runs, x.(*dumpRun)) }
+
+func (h *dumpRunHeap) Pop() interface{} {
+	run := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return run
+}
+
+func (h *dumpRunHeap) close() {
+	for _, run := range h.runs {
+		run.f.Close()
+	}
+	h.runs = nil
+}
@@ -0,0 +1,380 @@
+package discogs
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Структуры XML дампов Discogs (https://data.discogs.com).
+// Записи дампов преобразуются к структурам ответов Discogs API, что позволяет использовать
+// при работе с локальным хранилищем то же преобразование в md.Release, что и для API.
+
+type xmlRef struct {
+	ID   int32  `xml:"id,attr"`
+	Name string `xml:",chardata"`
+}
+
+type xmlImage struct {
+	Type   string `xml:"type,attr"`
+	URI    string `xml:"uri,attr"`
+	URI150 string `xml:"uri150,attr"`
+	Width  int16  `xml:"width,attr"`
+	Height int16  `xml:"height,attr"`
+}
+
+type xmlArtist struct {
+	ID     int32  `xml:"id"`
+	Name   string `xml:"name"`
+	Anv    string `xml:"anv"`
+	Join   string `xml:"join"`
+	Role   string `xml:"role"`
+	Tracks string `xml:"tracks"`
+}
+
+type xmlLabel struct {
+	ID    int32  `xml:"id,attr"`
+	Name  string `xml:"name,attr"`
+	Catno string `xml:"catno,attr"`
+}
+
+type xmlFormat struct {
+	Name         string   `xml:"name,attr"`
+	Qty          string   `xml:"qty,attr"`
+	Text         string   `xml:"text,attr"`
+	Descriptions []string `xml:"descriptions>description"`
+}
+
+type xmlTrack struct {
+	Position     string      `xml:"position"`
+	Title        string      `xml:"title"`
+	Duration     string      `xml:"duration"`
+	ExtraArtists []xmlArtist `xml:"extraartists>artist"`
+	SubTracks    []xmlTrack  `xml:"sub_tracks>track"`
+}
+
+type xmlIdentifier struct {
+	Type        string `xml:"type,attr"`
+	Value       string `xml:"value,attr"`
+	Description string `xml:"description,attr"`
+}
+
+type xmlCompany struct {
+	ID             int32  `xml:"id"`
+	Name           string `xml:"name"`
+	Catno          string `xml:"catno"`
+	EntityType     string `xml:"entity_type"`
+	EntityTypeName string `xml:"entity_type_name"`
+	ResourceURL    string `xml:"resource_url"`
+}
+
+type xmlMasterID struct {
+	ID            int32 `xml:",chardata"`
+	IsMainRelease bool  `xml:"is_main_release,attr"`
+}
+
+type xmlRelease struct {
+	ID           int32           `xml:"id,attr"`
+	Status       string          `xml:"status,attr"`
+	Images       []xmlImage      `xml:"images>image"`
+	Artists      []xmlArtist     `xml:"artists>artist"`
+	Title        string          `xml:"title"`
+	Labels       []xmlLabel      `xml:"labels>label"`
+	ExtraArtists []xmlArtist     `xml:"extraartists>artist"`
+	Formats      []xmlFormat     `xml:"formats>format"`
+	Genres       []string        `xml:"genres>genre"`
+	Styles       []string        `xml:"styles>style"`
+	Country      string          `xml:"country"`
+	Released     string          `xml:"released"`
+	Notes        string          `xml:"notes"`
+	MasterID     xmlMasterID     `xml:"master_id"`
+	Tracklist    []xmlTrack      `xml:"tracklist>track"`
+	Identifiers  []xmlIdentifier `xml:"identifiers>identifier"`
+	Companies    []xmlCompany    `xml:"companies>company"`
+}
+
+type xmlMaster struct {
+	ID          int32       `xml:"id,attr"`
+	MainRelease int32       `xml:"main_release"`
+	Images      []xmlImage  `xml:"images>image"`
+	Artists     []xmlArtist `xml:"artists>artist"`
+	Genres      []string    `xml:"genres>genre"`
+	Styles      []string    `xml:"styles>style"`
+	Year        int32       `xml:"year"`
+	Title       string      `xml:"title"`
+	Notes       string      `xml:"notes"`
+}
+
+type xmlArtistProfile struct {
+	ID             int32      `xml:"id"`
+	Name           string     `xml:"name"`
+	RealName       string     `xml:"realname"`
+	Profile        string     `xml:"profile"`
+	DataQuality    string     `xml:"data_quality"`
+	URLs           []string   `xml:"urls>url"`
+	NameVariations []string   `xml:"namevariations>name"`
+	Aliases        []xmlRef   `xml:"aliases>name"`
+	Members        []xmlRef   `xml:"members>name"`
+	Groups         []xmlRef   `xml:"groups>name"`
+	Images         []xmlImage `xml:"images>image"`
+}
+
+type xmlLabelProfile struct {
+	ID          int32      `xml:"id"`
+	Name        string     `xml:"name"`
+	ContactInfo string     `xml:"contactinfo"`
+	Profile     string     `xml:"profile"`
+	DataQuality string     `xml:"data_quality"`
+	URLs        []string   `xml:"urls>url"`
+	Sublabels   []xmlRef   `xml:"sublabels>label"`
+	ParentLabel *xmlRef    `xml:"parentLabel"`
+	Images      []xmlImage `xml:"images>image"`
+}
+
+func (x *xmlRelease) info() *releaseInfo {
+	id := strconv.Itoa(int(x.ID))
+	ri := &releaseInfo{
+		ID:           x.ID,
+		Title:        x.Title,
+		Artists:      xmlArtists(x.Artists),
+		ExtraArtists: xmlArtists(x.ExtraArtists),
+		Images:       xmlImages(x.Images),
+		Genres:       x.Genres,
+		Styles:       x.Styles,
+		Country:      x.Country,
+		Released:     x.Released,
+		Year:         releasedYear(x.Released),
+		Notes:        x.Notes,
+		MasterID:     x.MasterID.ID,
+		ResourceURL:  BaseURL + "releases/" + id,
+	}
+	if x.MasterID.ID != 0 {
+		ri.MasterURL = BaseURL + "masters/" + strconv.Itoa(int(x.MasterID.ID))
+	}
+	for _, lbl := range x.Labels {
+		ri.Labels = append(ri.Labels, label{
+			ID:          lbl.ID,
+			Name:        lbl.Name,
+			Catno:       lbl.Catno,
+			ResourceURL: BaseURL + "labels/" + strconv.Itoa(int(lbl.ID)),
+		})
+	}
+	for _, f := range x.Formats {
+		ri.Formats = append(ri.Formats, format{
+			Name: f.Name, Qty: f.Qty, Text: f.Text, Descriptions: f.Descriptions})
+	}
+	for _, tr := range x.Tracklist {
+		ri.Tracklist = append(ri.Tracklist, tr.track())
+	}
+	for _, id := range x.Identifiers {
+		ri.Identifiers = append(ri.Identifiers, identifier{
+			Type: id.Type, Value: id.Value, Description: id.Description})
+	}
+	for _, c := range x.Companies {
+		ri.Companies = append(ri.Companies, company{
+			ID:             c.ID,
+			Name:           c.Name,
+			Catno:          c.Catno,
+			EntityType:     c.EntityType,
+			EntityTypeName: c.EntityTypeName,
+			ResourceURL:    c.ResourceURL,
+		})
+	}
+	return ri
+}
+
+// Поисковая запись релиза.
+func (x *xmlRelease) entry() *dumpEntry {
+	e := &dumpEntry{
+		ID:       x.ID,
+		Title:    x.Title,
+		Country:  x.Country,
+		Released: x.Released,
+		Year:     releasedYear(x.Released),
+		Status:   x.Status,
+		MasterID: x.MasterID.ID,
+		Thumb:    xmlThumb(x.Images),
+	}
+	for _, a := range x.Artists {
+		e.Artists = append(e.Artists, a.Name)
+	}
+	for _, lbl := range x.Labels {
+		e.Labels = append(e.Labels, lbl.Name)
+		e.LabelIDs = append(e.LabelIDs, lbl.ID)
+		e.Catnos = append(e.Catnos, lbl.Catno)
+	}
+	var descriptions []string
+	for _, f := range x.Formats {
+		e.Formats = append(e.Formats, f.Name)
+		descriptions = append(descriptions, f.Name)
+		descriptions = append(descriptions, f.Descriptions...)
+	}
+	e.Format = strings.Join(descriptions, ", ")
+	for _, id := range x.Identifiers {
+		if id.Type == "Barcode" {
+			e.Barcodes = append(e.Barcodes, id.Value)
+		}
+	}
+	return e
+}
+
+func (x *xmlMaster) info() *masterInfo {
+	id := strconv.Itoa(int(x.ID))
+	mi := &masterInfo{
+		ID:          x.ID,
+		MainRelease: x.MainRelease,
+		Title:       x.Title,
+		Year:        x.Year,
+		Notes:       x.Notes,
+		Genres:      x.Genres,
+		Styles:      x.Styles,
+		Artists:     xmlArtists(x.Artists),
+		Images:      xmlImages(x.Images),
+		ResourceURL: BaseURL + "masters/" + id,
+		VersionsURL: BaseURL + "masters/" + id + "/versions",
+	}
+	if x.MainRelease != 0 {
+		mi.MainReleaseURL = BaseURL + "releases/" + strconv.Itoa(int(x.MainRelease))
+	}
+	return mi
+}
+
+func (x *xmlMaster) entry() *dumpEntry {
+	e := &dumpEntry{ID: x.ID, Title: x.Title, Year: x.Year, Thumb: xmlThumb(x.Images)}
+	for _, a := range x.Artists {
+		e.Artists = append(e.Artists, a.Name)
+	}
+	return e
+}
+
+func (x *xmlArtistProfile) info() *artistInfo {
+	id := strconv.Itoa(int(x.ID))
+	return &artistInfo{
+		ID:             x.ID,
+		Name:           x.Name,
+		RealName:       x.RealName,
+		Profile:        x.Profile,
+		URLs:           x.URLs,
+		NameVariations: x.NameVariations,
+		Aliases:        xmlArtistRefs(x.Aliases),
+		Members:        xmlArtistRefs(x.Members),
+		Groups:         xmlArtistRefs(x.Groups),
+		Images:         xmlImages(x.Images),
+		ResourceURL:    BaseURL + "artists/" + id,
+		ReleasesURL:    BaseURL + "artists/" + id + "/releases",
+		DataQuality:    x.DataQuality,
+	}
+}
+
+func (x *xmlLabelProfile) info() *labelInfo {
+	id := strconv.Itoa(int(x.ID))
+	li := &labelInfo{
+		ID:          x.ID,
+		Name:        x.Name,
+		Profile:     x.Profile,
+		ContactInfo: x.ContactInfo,
+		URLs:        x.URLs,
+		Images:      xmlImages(x.Images),
+		ResourceURL: BaseURL + "labels/" + id,
+		ReleasesURL: BaseURL + "labels/" + id + "/releases",
+		DataQuality: x.DataQuality,
+	}
+	if x.ParentLabel != nil {
+		li.ParentLabel = &labelRef{
+			ID:          x.ParentLabel.ID,
+			Name:        x.ParentLabel.Name,
+			ResourceURL: BaseURL + "labels/" + strconv.Itoa(int(x.ParentLabel.ID)),
+		}
+	}
+	for _, ref := range x.Sublabels {
+		li.Sublabels = append(li.Sublabels, labelRef{
+			ID:          ref.ID,
+			Name:        ref.Name,
+			ResourceURL: BaseURL + "labels/" + strconv.Itoa(int(ref.ID)),
+		})
+	}
+	return li
+}
+
+func (x *xmlTrack) track() track {
+	tr := track{
+		Position:     x.Position,
+		Title:        x.Title,
+		Duration:     x.Duration,
+		Type:         "track",
+		ExtraArtists: xmlArtists(x.ExtraArtists),
+	}
+	if x.Position == "" && len(x.SubTracks) == 0 {
+		tr.Type = "heading"
+	}
+	for _, sub := range x.SubTracks {
+		tr.SubTracks = append(tr.SubTracks, sub.track())
+	}
+	return tr
+}
+
+func xmlArtists(xartists []xmlArtist) []artist {
+	var artists []artist
+	for _, a := range xartists {
+		artists = append(artists, artist{
+			ID:          a.ID,
+			Name:        a.Name,
+			Anv:         a.Anv,
+			Join:        a.Join,
+			Role:        a.Role,
+			Tracks:      a.Tracks,
+			ResourceURL: BaseURL + "artists/" + strconv.Itoa(int(a.ID)),
+		})
+	}
+	return artists
+}
+
+func xmlArtistRefs(refs []xmlRef) []artistRef {
+	var artists []artistRef
+	for _, ref := range refs {
+		artists = append(artists, artistRef{
+			ID:          ref.ID,
+			Name:        ref.Name,
+			Active:      true,
+			ResourceURL: BaseURL + "artists/" + strconv.Itoa(int(ref.ID)),
+		})
+	}
+	return artists
+}
+
+func xmlImages(ximages []xmlImage) []image {
+	var images []image
+	for _, img := range ximages {
+		images = append(images, image{
+			Type:   img.Type,
+			URI:    img.URI,
+			URI150: img.URI150,
+			Width:  img.Width,
+			Height: img.Height,
+		})
+	}
+	return images
+}
+
+func xmlThumb(images []xmlImage) string {
+	for _, img := range images {
+		if img.Type == "primary" {
+			return img.URI150
+		}
+	}
+	if len(images) > 0 {
+		return images[0].URI150
+	}
+	return ""
+}
+
+// Год из даты выпуска дампа вида "1999-03-00".
+func releasedYear(released string) int32 {
+	if len(released) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(released[:4])
+	if err != nil {
+		return 0
+	}
+	return int32(year)
+}
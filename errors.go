@@ -0,0 +1,110 @@
+package discogs
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Ошибки выполнения команд, не связанные с Discogs API.
+var (
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrUnknownCommand = errors.New("unknown command")
+)
+
+// ErrorCode - машиночитаемый код ошибки в ответе микросервиса.
+type ErrorCode string
+
+// Коды ошибок ответа микросервиса.
+const (
+	// Сущность не найдена в БД Discogs.
+	CodeNotFound ErrorCode = "not_found"
+	// Исчерпан бюджет запросов к Discogs API.
+	CodeRateLimited ErrorCode = "rate_limited"
+	// Discogs API недоступен или ответил ошибкой сервера.
+	CodeUpstreamUnavailable ErrorCode = "upstream_unavailable"
+	// Некорректный запрос к микросервису (в т.ч. неизвестная команда).
+	CodeInvalidRequest ErrorCode = "invalid_request"
+	// Discogs API отклонил авторизацию микросервиса.
+	CodeUnauthorized ErrorCode = "unauthorized"
+	// Внутренняя ошибка микросервиса.
+	CodeInternal ErrorCode = "internal"
+)
+
+// ErrorResponse описывает ошибку в ответе микросервиса. Поля Error и Context совместимы
+// с srv.ErrorResponse. Retryable сообщает, может ли повтор запроса быть успешным,
+// RetryAfter - рекомендуемую задержку повтора в секундах (из заголовка Retry-After
+// ответа Discogs API).
+type ErrorResponse struct {
+	Error      string    `json:"error,omitempty"`
+	Context    string    `json:"context,omitempty"`
+	Code       ErrorCode `json:"code,omitempty"`
+	Retryable  bool      `json:"retryable,omitempty"`
+	RetryAfter int       `json:"retry_after,omitempty"`
+}
+
+// NewErrorResponse формирует описание ошибки выполнения команды в контексте `context`.
+func NewErrorResponse(err error, context string) *ErrorResponse {
+	resp := &ErrorResponse{
+		Error:   err.Error(),
+		Context: context,
+		Code:    ErrorCodeOf(err),
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		resp.RetryAfter = int((statusErr.RetryAfter + time.Second - 1) / time.Second)
+	}
+	resp.Retryable = resp.Code == CodeRateLimited || resp.Code == CodeUpstreamUnavailable
+	return resp
+}
+
+// ErrorCodeOf определяет код ошибки выполнения команды. Коды ошибок Discogs API
+// определяются по HTTP статусу ответа.
+func ErrorCodeOf(err error) ErrorCode {
+	var statusErr *StatusError
+	var serviceErr *ServiceError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &serviceErr):
+		return serviceErr.Code
+	case errors.Is(err, ErrInvalidRequest), errors.Is(err, ErrUnknownCommand):
+		return CodeInvalidRequest
+	case errors.As(err, &statusErr):
+		switch code := statusErr.StatusCode; {
+		case code == http.StatusNotFound:
+			return CodeNotFound
+		case code == http.StatusTooManyRequests:
+			return CodeRateLimited
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return CodeUnauthorized
+		case code >= http.StatusInternalServerError:
+			return CodeUpstreamUnavailable
+		}
+		return CodeInvalidRequest
+	case errors.As(err, &netErr):
+		return CodeUpstreamUnavailable
+	}
+	return CodeInternal
+}
+
+// ServiceError описывает ошибку выполнения запроса, полученную в ответе микросервиса.
+type ServiceError struct {
+	Message    string
+	Context    string
+	Code       ErrorCode
+	Retryable  bool
+	RetryAfter time.Duration
+}
+
+func (e *ServiceError) Error() string {
+	if e.Context != "" {
+		return e.Context + ": " + e.Message
+	}
+	return e.Message
+}
+
+// Temporary сообщает, может ли повтор запроса быть успешным.
+func (e *ServiceError) Temporary() bool {
+	return e.Retryable
+}
@@ -0,0 +1,52 @@
+package discogs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		code ErrorCode
+	}{
+		{&StatusError{StatusCode: http.StatusNotFound}, CodeNotFound},
+		{&StatusError{StatusCode: http.StatusTooManyRequests}, CodeRateLimited},
+		{&StatusError{StatusCode: http.StatusForbidden}, CodeUnauthorized},
+		{&StatusError{StatusCode: http.StatusBadGateway}, CodeUpstreamUnavailable},
+		{&StatusError{StatusCode: http.StatusBadRequest}, CodeInvalidRequest},
+		{&url.Error{Op: "Get", URL: BaseURL, Err: errors.New("connection refused")}, CodeUpstreamUnavailable},
+		{fmt.Errorf("%w: actor data is absent", ErrInvalidRequest), CodeInvalidRequest},
+		{fmt.Errorf("%w: x", ErrUnknownCommand), CodeInvalidRequest},
+		{&ServiceError{Code: CodeNotFound}, CodeNotFound},
+		{errors.New("cache is not configured"), CodeInternal},
+	} {
+		assert.Equal(t, tc.code, ErrorCodeOf(tc.err), tc.err.Error())
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	resp := NewErrorResponse(
+		&StatusError{URL: BaseURL, StatusCode: http.StatusTooManyRequests, RetryAfter: 1500 * time.Millisecond},
+		"release")
+	assert.Equal(t, CodeRateLimited, resp.Code)
+	assert.True(t, resp.Retryable)
+	assert.Equal(t, 2, resp.RetryAfter)
+
+	resp = NewErrorResponse(&StatusError{URL: BaseURL, StatusCode: http.StatusNotFound}, "release")
+	assert.Equal(t, CodeNotFound, resp.Code)
+	assert.False(t, resp.Retryable)
+
+	err := (&AudioOnlineResponse{Error: &ErrorResponse{
+		Error: "rate limited", Code: CodeRateLimited, Retryable: true, RetryAfter: 3}}).Err()
+	var serviceErr *ServiceError
+	assert.True(t, errors.As(err, &serviceErr))
+	assert.True(t, serviceErr.Temporary())
+	assert.Equal(t, 3*time.Second, serviceErr.RetryAfter)
+}
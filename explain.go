@@ -0,0 +1,163 @@
+package discogs
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+	tp "github.com/ytsiuryn/go-stringutils"
+)
+
+// Explanation описывает оценку сходства предложения с запрошенным релизом: итоговую оценку
+// предложения, ее составляющие (сравнение релизов и, если возможно, списков треков) и
+// сравнение отдельных полей. Поля, не указанные в запросе, не сравниваются.
+type Explanation struct {
+	Score     float64       `json:"score"`
+	Release   float64       `json:"release"`
+	Tracklist *float64      `json:"tracklist,omitempty"`
+	Fields    []*FieldScore `json:"fields,omitempty"`
+}
+
+// FieldScore описывает сравнение поля запрошенного релиза с полем предложения.
+type FieldScore struct {
+	Field     string  `json:"field"`
+	Requested string  `json:"requested"`
+	Candidate string  `json:"candidate"`
+	Score     float64 `json:"score"`
+}
+
+func (e *Explanation) String() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, fmt.Sprintf("%s=%.2f (%q ~ %q)", f.Field, f.Score, f.Requested, f.Candidate))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// explainScore сравнивает поля релиза-кандидата с полями запрошенного релиза.
+// Итоговая оценка предложения (Score) заполняется вызывающим.
+func explainScore(release, candidate *md.Release) *Explanation {
+	e := &Explanation{Release: release.Compare(candidate)}
+	if score, ok := tracklistCompare(release, candidate); ok {
+		e.Tracklist = &score
+	}
+	add := func(field, requested, other string, score float64) {
+		e.Fields = append(e.Fields, &FieldScore{field, requested, other, score})
+	}
+
+	if release.Title != "" {
+		add("title", release.Title, candidate.Title, tp.JaroWinklerDistance(release.Title, candidate.Title))
+	}
+	performers := release.ActorRoles.Filter(md.IsPerformer)
+	if len(performers) > 0 {
+		other := candidate.ActorRoles.Filter(md.IsPerformer)
+		add("artist", actorNames(performers), actorNames(other), performers.Compare(other))
+	}
+	if release.Year != 0 {
+		add("year", strconv.Itoa(release.Year), strconv.Itoa(candidate.Year), equalScore(release.Year == candidate.Year))
+	}
+	if release.Publishing != nil && candidate.Publishing != nil {
+		explainLabels(add, release.Publishing.Labels, candidate.Publishing.Labels)
+	}
+	if len(release.Tracks) > 0 {
+		n, m := len(release.Tracks), len(candidate.Tracks)
+		add("track_count", strconv.Itoa(n), strconv.Itoa(m),
+			1.-math.Abs(float64(n-m))/math.Max(float64(n), float64(m)))
+		explainDurations(add, release, candidate)
+	}
+	if media := discMedia(release); len(media) > 0 {
+		other := discMedia(candidate)
+		matched := false
+		for m := range media {
+			matched = matched || other[m]
+		}
+		add("format", mediaNames(media), mediaNames(other), equalScore(matched))
+	}
+	if release.Country != "" {
+		add("country", release.Country, candidate.Country, equalScore(strings.EqualFold(release.Country, candidate.Country)))
+	}
+	return e
+}
+
+func explainLabels(add func(string, string, string, float64), labels, other []*md.Label) {
+	var names, catnos, otherNames, otherCatnos []string
+	for _, lbl := range other {
+		otherNames = appendNonEmpty(otherNames, lbl.Label)
+		otherCatnos = appendNonEmpty(otherCatnos, lbl.Catno)
+	}
+	var nameScore, catnoScore float64
+	for _, lbl := range labels {
+		names = appendNonEmpty(names, lbl.Label)
+		catnos = appendNonEmpty(catnos, lbl.Catno)
+		for _, otherLbl := range other {
+			if lbl.Label != "" {
+				if score := compareNames(lbl.Label, otherLbl.Label); score > nameScore {
+					nameScore = score
+				}
+			}
+			if lbl.Catno != "" && normalizeCatno(lbl.Catno) == normalizeCatno(otherLbl.Catno) {
+				catnoScore = 1.
+			}
+		}
+	}
+	if len(names) > 0 {
+		add("label", strings.Join(names, "; "), strings.Join(otherNames, "; "), nameScore)
+	}
+	if len(catnos) > 0 {
+		add("catno", strings.Join(catnos, "; "), strings.Join(otherCatnos, "; "), catnoScore)
+	}
+}
+
+// Длительности треков сравниваются по порядку следования (см. tracklistCompare).
+func explainDurations(add func(string, string, string, float64), release, candidate *md.Release) {
+	var requested, other []string
+	var sum float64
+	var n int
+	for i := 0; i < len(release.Tracks) && i < len(candidate.Tracks); i++ {
+		tr, otherTr := release.Tracks[i], candidate.Tracks[i]
+		if tr.Duration > 0 && otherTr.Duration > 0 {
+			requested = append(requested, tr.Duration.String())
+			other = append(other, otherTr.Duration.String())
+			sum += durationCompare(tr.Duration, otherTr.Duration)
+			n++
+		}
+	}
+	if n > 0 {
+		add("durations", strings.Join(requested, " "), strings.Join(other, " "), sum/float64(n))
+	}
+}
+
+func actorNames(roles md.ActorRoles) string {
+	names := make([]string, 0, len(roles))
+	for name := range roles {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return strings.Join(names, "; ")
+}
+
+func mediaNames(media map[md.Media]bool) string {
+	names := make([]string, 0, len(media))
+	for m := range media {
+		names = append(names, m.String())
+	}
+	sort.Strings(names)
+	return strings.Join(names, "; ")
+}
+
+func appendNonEmpty(values []string, value string) []string {
+	if value == "" {
+		return values
+	}
+	return append(values, value)
+}
+
+func equalScore(equal bool) float64 {
+	if equal {
+		return 1.
+	}
+	return 0.
+}
@@ -0,0 +1,53 @@
+package discogs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+func TestExplainScore(t *testing.T) {
+	request := newTracklistRelease("Speak To Me", "1:13", "Breathe", "2:43")
+	request.Title = "The Dark Side Of The Moon"
+	request.Year = 1973
+	request.Country = "UK"
+	request.ActorRoles.Add("Pink Floyd", "performer")
+	request.Disc(1).Format.Media = md.MediaLP
+	request.Publishing.AddLabel(md.NewLabel("Harvest", "SHVL 804"))
+
+	candidate := newTracklistRelease("Speak To Me", "1:30", "Breathe", "2:43", "Money", "6:22")
+	candidate.Title = "The Dark Side Of The Moon"
+	candidate.Year = 1979
+	candidate.Country = "uk"
+	candidate.ActorRoles.Add("Pink Floyd", "performer")
+	candidate.Disc(1).Format.Media = md.MediaCD
+	candidate.Publishing.AddLabel(md.NewLabel("Harvest", "SHVL-804"))
+
+	e := explainScore(request, candidate)
+	require.NotNil(t, e.Tracklist)
+	scores := map[string]float64{}
+	for _, f := range e.Fields {
+		scores[f.Field] = f.Score
+	}
+	assert.InDelta(t, 2./3, scores["track_count"], 1e-9)
+	delete(scores, "track_count")
+	assert.Equal(t, map[string]float64{
+		"title":     1.,
+		"artist":    1.,
+		"year":      0.,
+		"label":     1.,
+		"catno":     1.,
+		"durations": .5,
+		"format":    0.,
+		"country":   1.,
+	}, scores)
+	assert.Contains(t, e.String(), `year=0.00 ("1973" ~ "1979")`)
+
+	// поля, не указанные в запросе, не сравниваются
+	e = explainScore(md.NewRelease(), candidate)
+	assert.Empty(t, e.Fields)
+	assert.Nil(t, e.Tracklist)
+}
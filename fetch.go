@@ -0,0 +1,126 @@
+package discogs
+
+import (
+	"strconv"
+	"sync"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+// MaxParallelFetches - количество релизов-кандидатов, загружаемых одновременно.
+// Частота обращений к Discogs API при этом ограничивается общим бюджетом запросов.
+const MaxParallelFetches = 4
+
+// fetcher загружает релизы в рамках одного поиска, если `withMasters` = true - вместе
+// со сведениями их мастер-релизов. Мастер-релиз, общий для нескольких кандидатов,
+// загружается однократно.
+type fetcher struct {
+	d           *Discogs
+	withMasters bool
+	sem         chan struct{}
+	mu          sync.Mutex
+	masters     map[string]*masterCall
+}
+
+// masterCall - загрузка мастер-релиза, результат которой ожидают все запросившие его кандидаты.
+type masterCall struct {
+	done chan struct{}
+	info masterInfo
+	err  error
+}
+
+func (d *Discogs) newFetcher(withMasters bool) *fetcher {
+	return &fetcher{
+		d:           d,
+		withMasters: withMasters,
+		sem:         make(chan struct{}, MaxParallelFetches),
+		masters:     map[string]*masterCall{},
+	}
+}
+
+func (f *fetcher) release(id string, release *md.Release) error {
+	// сведения о релизе...
+	var releaseResp releaseInfo
+	data, err := f.d.backend.Release(id)
+	if err = decodeDoc(data, err, &releaseResp); err != nil {
+		return err
+	}
+	releaseResp.Release(release)
+	// сведения о мастер-релизе...
+	if f.withMasters && releaseResp.MasterID != 0 {
+		masterResp, err := f.master(strconv.Itoa(int(releaseResp.MasterID)))
+		if err != nil {
+			return err
+		}
+		masterResp.Master(release)
+	}
+	return nil
+}
+
+func (f *fetcher) master(id string) (*masterInfo, error) {
+	f.mu.Lock()
+	call, ok := f.masters[id]
+	if ok {
+		f.mu.Unlock()
+		<-call.done
+		return &call.info, call.err
+	}
+	call = &masterCall{done: make(chan struct{})}
+	f.masters[id] = call
+	f.mu.Unlock()
+
+	data, err := f.d.backend.Master(id)
+	call.err = decodeDoc(data, err, &call.info)
+	close(call.done)
+	return &call.info, call.err
+}
+
+// candidates параллельно загружает релизы предложений по их ID в БД Discogs. Загруженное
+// предложение оценивается функцией `accept` (если задана) и исключается, если она вернула
+// false; `accept` может вызываться одновременно из нескольких горутин.
+// Предложение, релиз которого не удалось загрузить, исключается с предупреждением в журнале.
+// Ошибка возвращается, только если не удалось загрузить ни одного релиза.
+func (f *fetcher) candidates(
+	suggestions []*md.Suggestion, accept func(*md.Suggestion) bool) ([]*md.Suggestion, error) {
+	errs := make([]error, len(suggestions))
+	accepted := make([]bool, len(suggestions))
+	var wg sync.WaitGroup
+	for i, s := range suggestions {
+		wg.Add(1)
+		f.sem <- struct{}{}
+		go func(i int, s *md.Suggestion) {
+			defer func() {
+				<-f.sem
+				wg.Done()
+			}()
+			if errs[i] = f.release(s.Release.IDs[md.DiscogsReleaseID], s.Release); errs[i] == nil {
+				accepted[i] = accept == nil || accept(s)
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	var loaded []*md.Suggestion
+	var firstErr error
+	failed := 0
+	for i, s := range suggestions {
+		if errs[i] == nil {
+			if accepted[i] {
+				loaded = append(loaded, s)
+			}
+			continue
+		}
+		failed++
+		if firstErr == nil {
+			firstErr = errs[i]
+		}
+		f.d.Log.
+			WithField("release", s.Release.IDs[md.DiscogsReleaseID]).
+			WithError(errs[i]).
+			Warn("Candidate release is skipped")
+	}
+	if failed > 0 && failed == len(suggestions) {
+		return nil, firstErr
+	}
+	return loaded, nil
+}
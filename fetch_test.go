@@ -0,0 +1,71 @@
+package discogs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+// brokenReleaseBackend отвечает ошибкой на запросы релизов из списка `broken`.
+type brokenReleaseBackend struct {
+	Backend
+	broken map[string]bool
+}
+
+func (b *brokenReleaseBackend) Release(id string) ([]byte, error) {
+	if b.broken[id] {
+		return nil, &StatusError{URL: "releases/" + id, StatusCode: http.StatusInternalServerError}
+	}
+	return b.Backend.Release(id)
+}
+
+func TestFetchCandidates(t *testing.T) {
+	d, fake := newFakeDiscogs(t)
+
+	// кандидаты с общим мастер-релизом
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "release.json"))
+	require.NoError(t, err)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	for _, id := range []int{4139589, 4139590} {
+		doc["id"] = id
+		data, err = json.Marshal(doc)
+		require.NoError(t, err)
+		require.NoError(t, fake.AddRelease(data))
+	}
+	backend := &brokenReleaseBackend{Backend: d.backend, broken: map[string]bool{"4139589": true}}
+	d.backend = backend
+
+	r := md.NewRelease()
+	r.Title = "The Dark Side Of The Moon"
+	r.ActorRoles.Add("Pink Floyd", "performer")
+	set, err := d.searchReleaseByIncompleteData(r, &d.search, nil)
+	require.NoError(t, err)
+	var ids []string
+	for _, s := range set.Suggestions {
+		ids = append(ids, s.Release.IDs[md.DiscogsReleaseID])
+		assert.Equal(t, 1973, s.Release.Original.Year)
+	}
+	assert.ElementsMatch(t, []string{"4139588", "4139590"}, ids)
+
+	masters := 0
+	for _, uri := range fake.Requests() {
+		if strings.HasPrefix(uri, "/masters/10362") && !strings.Contains(uri, "versions") {
+			masters++
+		}
+	}
+	assert.Equal(t, 1, masters)
+
+	// ошибка загрузки всех кандидатов возвращается вызывающему
+	backend.broken["4139588"], backend.broken["4139590"] = true, true
+	_, err = d.searchReleaseByIncompleteData(r, &d.search, nil)
+	assert.Equal(t, CodeUpstreamUnavailable, ErrorCodeOf(err))
+}
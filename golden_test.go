@@ -0,0 +1,106 @@
+package discogs
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+	"github.com/ytsiuryn/ds-discogs/discogstest"
+)
+
+// Запись ответов Discogs API (требует DISCOGS_APP/DISCOGS_PERSONAL_TOKEN):
+//
+//	go test -run Golden -record
+//
+// Обновление эталонных результатов после намеренного изменения преобразования:
+//
+//	go test -run Golden -update
+var (
+	record = flag.Bool("record", false, "record Discogs API responses into testdata/replay")
+	update = flag.Bool("update", false, "update golden files in testdata/golden")
+)
+
+// Клиент Discogs, воспроизводящий записанные ответы Discogs API.
+func newReplayDiscogs(t *testing.T) *Discogs {
+	if *record && (os.Getenv("DISCOGS_APP") == "" || os.Getenv("DISCOGS_PERSONAL_TOKEN") == "") {
+		t.Fatal("recording requires DISCOGS_APP and DISCOGS_PERSONAL_TOKEN")
+	}
+	d := New(
+		os.Getenv("DISCOGS_APP"),
+		os.Getenv("DISCOGS_PERSONAL_TOKEN"),
+		WithHTTPClient(&http.Client{
+			Transport: discogstest.NewRecorder(filepath.Join("testdata", "replay"), *record),
+		}))
+	if !*record {
+		d.api.limiter.setLimit(0)
+	}
+	return d
+}
+
+// Сравнение JSON представления значения с эталоном testdata/golden/<name>.json.
+func assertGolden(t *testing.T, name string, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	require.NoError(t, err)
+	data = append(data, '\n')
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *update {
+		require.NoError(t, ioutil.WriteFile(path, data, 0644))
+		return
+	}
+	golden, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "run `go test -update` to create the golden file")
+	assert.JSONEq(t, string(golden), string(data))
+}
+
+func TestGoldenRelease(t *testing.T) {
+	d := newReplayDiscogs(t)
+	r := md.NewRelease()
+	require.NoError(t, d.releaseByID("4139588", r))
+	assertGolden(t, "release_4139588", r)
+}
+
+func TestGoldenTracks(t *testing.T) {
+	d := newReplayDiscogs(t)
+	var releaseResp releaseInfo
+	data, err := d.backend.Release("4139588")
+	require.NoError(t, decodeDoc(data, err, &releaseResp))
+	var tracks []*md.Track
+	for _, tr := range releaseResp.Tracklist {
+		tracks = append(tracks, tr.Track())
+	}
+	assertGolden(t, "tracks_4139588", tracks)
+}
+
+func TestGoldenMaster(t *testing.T) {
+	d := newReplayDiscogs(t)
+	master, err := d.masterByID("10362")
+	require.NoError(t, err)
+	require.NoError(t, d.masterVersions(master, "10362", nil, nil))
+	assertGolden(t, "master_10362", master)
+
+	var masterResp masterInfo
+	data, err := d.backend.Master("10362")
+	require.NoError(t, decodeDoc(data, err, &masterResp))
+	r := md.NewRelease()
+	masterResp.Master(r)
+	assertGolden(t, "master_10362_original", r.Original)
+}
+
+func TestGoldenSearch(t *testing.T) {
+	d := newReplayDiscogs(t)
+	r := md.NewRelease()
+	r.Title = "The Dark Side Of The Moon"
+	r.ActorRoles.Add("Pink Floyd", "performer")
+	var searchResp searchResponse
+	data, err := d.backend.Search(searchParams(r, "release"))
+	require.NoError(t, decodeDoc(data, err, &searchResp))
+	assertGolden(t, "search", searchResp.Search())
+}
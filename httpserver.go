@@ -0,0 +1,142 @@
+package discogs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	srv "github.com/ytsiuryn/ds-microservice"
+)
+
+// HTTP режим микросервиса: команды принимаются запросами `POST /v1/<cmd>` с телом
+// AudioOnlineRequest и возвращают AudioOnlineResponse, как и при обмене через RabbitMQ.
+// Команды без параметров (ping, info, cache) могут быть вызваны методом GET.
+const (
+	HTTPPathPrefix     = "/v1/"
+	RequestIDHeaderKey = "X-Request-ID"
+	MaxHTTPRequestSize = 1 << 20
+)
+
+// Команды, допускающие вызов методом GET.
+var httpGetCmds = map[string]bool{"ping": true, "info": true, "cache": true}
+
+// Handler возвращает обработчик HTTP запросов к командам микросервиса.
+func (d *Discogs) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(HTTPPathPrefix, d.serveCmd)
+	return mux
+}
+
+// StartHTTP запускает HTTP сервер команд микросервиса по адресу `addr` (например, ":8080").
+// Контролирует сигнал завершения и последующую остановку сервера.
+func (d *Discogs) StartHTTP(addr string) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           d.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go d.TestPollingInterval()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			srv.FailOnError(err, "HTTP server")
+		}
+	}()
+
+	d.Log.WithField("addr", addr).Info("Awaiting HTTP requests")
+	<-c
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	d.LogOnErrorWithContext(server.Shutdown(ctx), "HTTP server shutdown")
+	d.Log.Infoln("stopped")
+}
+
+func (d *Discogs) serveCmd(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get(RequestIDHeaderKey)
+	if requestID == "" {
+		id, _ := uuid.NewV4()
+		requestID = id.String()
+	}
+	w.Header().Set(RequestIDHeaderKey, requestID)
+
+	cmd := strings.Trim(strings.TrimPrefix(r.URL.Path, HTTPPathPrefix), "/")
+	if r.Method != http.MethodPost && (r.Method != http.MethodGet || !httpGetCmds[cmd]) {
+		w.Header().Set("Allow", "GET, POST")
+		d.answerHTTPError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"), cmd)
+		return
+	}
+
+	req := NewAudioOnlineRequest()
+	if r.Method == http.MethodPost {
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxHTTPRequestSize)).Decode(req)
+		if err != nil && err != io.EOF {
+			err = fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+			d.answerHTTPError(w, http.StatusBadRequest, err, "Message dispatcher")
+			return
+		}
+	}
+	req.Cmd = cmd
+	d.logRequest(requestID, req)
+
+	data, err := d.Execute(req)
+	if err != nil {
+		d.answerHTTPError(w, HTTPStatus(err), err, cmd)
+		return
+	}
+	d.Log.Debug(string(data))
+	if len(data) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// Ответ с информацией об ошибке в формате AudioOnlineResponse.
+func (d *Discogs) answerHTTPError(w http.ResponseWriter, status int, err error, context string) {
+	d.LogOnErrorWithContext(err, context)
+	resp := NewErrorResponse(err, context)
+	if resp.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(resp.RetryAfter))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(errorAnswer(resp))
+}
+
+// HTTPStatus определяет код HTTP ответа микросервиса по ошибке выполнения команды:
+// неизвестная команда - 404, остальные ошибки - по коду ошибки (invalid_request - 400,
+// not_found - 404, rate_limited - 429, unauthorized и upstream_unavailable - 502,
+// internal - 500).
+func HTTPStatus(err error) int {
+	if errors.Is(err, ErrUnknownCommand) {
+		return http.StatusNotFound
+	}
+	switch ErrorCodeOf(err) {
+	case CodeInvalidRequest:
+		return http.StatusBadRequest
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeRateLimited:
+		return http.StatusTooManyRequests
+	case CodeUnauthorized, CodeUpstreamUnavailable:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,96 @@
+package discogs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+func postCmd(t *testing.T, ts *httptest.Server, cmd, body string) (*http.Response, *AudioOnlineResponse) {
+	req, err := http.NewRequest(http.MethodPost, ts.URL+HTTPPathPrefix+cmd, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set(RequestIDHeaderKey, "test-"+cmd)
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "test-"+cmd, resp.Header.Get(RequestIDHeaderKey))
+	var out AudioOnlineResponse
+	if resp.StatusCode != http.StatusNoContent {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	}
+	return resp, &out
+}
+
+func TestHTTPServer(t *testing.T) {
+	d, _ := newFakeDiscogs(t)
+	ts := httptest.NewServer(d.Handler())
+	defer ts.Close()
+
+	resp, err := ts.Client().Get(ts.URL + HTTPPathPrefix + "ping")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get(RequestIDHeaderKey))
+
+	resp, out := postCmd(t, ts, "info", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotNil(t, out.Info)
+	assert.Equal(t, ServiceName, out.Info.Name)
+	assert.Equal(t, d.web.baseURL, out.Info.Backend)
+
+	r := md.NewRelease()
+	r.IDs[md.DiscogsReleaseID] = "4139588"
+	body, err := json.Marshal(&AudioOnlineRequest{Release: r})
+	require.NoError(t, err)
+	resp, out = postCmd(t, ts, "release", string(body))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotNil(t, out.SuggestionSet)
+	require.Len(t, out.SuggestionSet.Suggestions, 1)
+	assert.Equal(t, "The Dark Side Of The Moon", out.SuggestionSet.Suggestions[0].Release.Title)
+
+	r.IDs[md.DiscogsReleaseID] = "1"
+	body, err = json.Marshal(&AudioOnlineRequest{Release: r})
+	require.NoError(t, err)
+	resp, out = postCmd(t, ts, "release", string(body))
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.NotNil(t, out.Error)
+	assert.Equal(t, "release", out.Error.Context)
+	assert.Equal(t, CodeNotFound, out.Error.Code)
+
+	resp, out = postCmd(t, ts, "artist", `{}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, out.Error.Error, "actor data is absent")
+
+	resp, out = postCmd(t, ts, "release", `{"release": `)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, CodeInvalidRequest, out.Error.Code)
+
+	// кэш ответов Discogs API не подключен
+	resp, out = postCmd(t, ts, "cache", `{}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.NotNil(t, out.Error)
+	assert.Equal(t, CodeInvalidRequest, out.Error.Code)
+	assert.False(t, out.Error.Retryable)
+
+	resp, _ = postCmd(t, ts, "x", `{}`)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = ts.Client().Get(ts.URL + HTTPPathPrefix + "release")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestHTTPStatus(t *testing.T) {
+	assert.Equal(t, http.StatusTooManyRequests, HTTPStatus(&StatusError{StatusCode: http.StatusTooManyRequests}))
+	assert.Equal(t, http.StatusBadGateway, HTTPStatus(&StatusError{StatusCode: http.StatusServiceUnavailable}))
+	assert.Equal(t, http.StatusBadGateway, HTTPStatus(&StatusError{StatusCode: http.StatusUnauthorized}))
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatus(json.Unmarshal([]byte("{"), md.NewRelease())))
+}
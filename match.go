@@ -0,0 +1,223 @@
+package discogs
+
+import (
+	"math"
+	"strings"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+	intutils "github.com/ytsiuryn/go-intutils"
+	tp "github.com/ytsiuryn/go-stringutils"
+)
+
+// Tracklist matching constants
+const (
+	// Разница длительностей треков (в мс), при которой треки считаются совпадающими.
+	DurationTolerance = 2000
+	// Разница длительностей треков (в мс), начиная с которой треки считаются различными.
+	MaxDurationDiff = 10000
+	// Вес оценки списка треков относительно общей оценки сходства релизов.
+	TracklistWeight = 1.
+)
+
+// Master versions exploring constants
+const (
+	// Количество мастер-релизов кандидатов, версии которых исследуются.
+	MaxExploredMasters = 2
+	// Количество лучших версий мастер-релиза, загружаемых для полной оценки.
+	MaxExploredVersions = 3
+	// Количество страниц списка версий мастер-релиза (по MaxPageSize версий), просматриваемых
+	// при исследовании версий.
+	MaxExploredPages = 2
+	// Минимальная оценка сходства версии по данным издания.
+	MinVersionResult = .5
+)
+
+const (
+	trackCountWeight    = 1.
+	trackTitlesWeight   = 2.
+	trackDurationWeight = 3.
+)
+
+// scoreRelease оценивает сходство релиза-кандидата с запрошенным релизом.
+// Если оба релиза содержат списки треков, к общей оценке добавляется оценка списков треков.
+func scoreRelease(release, candidate *md.Release) float64 {
+	score := release.Compare(candidate)
+	if tracksScore, ok := tracklistCompare(release, candidate); ok {
+		score = (score + TracklistWeight*tracksScore) / (1 + TracklistWeight)
+	}
+	return score
+}
+
+// scoreRequestedFields оценивает сходство релиза-кандидата средней оценкой сравнения полей,
+// указанных в запросе (см. explainScore). В отличие от scoreRelease не снижает оценку за поля,
+// отсутствующие в запросе, например, при поиске только по номеру в каталоге.
+func scoreRequestedFields(release, candidate *md.Release) float64 {
+	fields := explainScore(release, candidate).Fields
+	if len(fields) == 0 {
+		return 0.
+	}
+	var sum float64
+	for _, f := range fields {
+		sum += f.Score
+	}
+	return sum / float64(len(fields))
+}
+
+// tracklistCompare сравнивает количество треков, их наименования и длительности.
+// Треки сопоставляются по порядку следования. Второй результат сообщает, возможно ли
+// сравнение (оба списка треков не пусты).
+func tracklistCompare(release, candidate *md.Release) (float64, bool) {
+	if len(release.Tracks) == 0 || len(candidate.Tracks) == 0 {
+		return 0., false
+	}
+	n, m := len(release.Tracks), len(candidate.Tracks)
+	countR := 1. - math.Abs(float64(n-m))/math.Max(float64(n), float64(m))
+	sum, weight := trackCountWeight*countR, trackCountWeight
+
+	var titlesSum, durationsSum float64
+	var titles, durations int
+	for i := 0; i < n && i < m; i++ {
+		tr, other := release.Tracks[i], candidate.Tracks[i]
+		if tr.Title != "" {
+			titlesSum += compareTrackTitles(tr.Title, other.Title)
+			titles++
+		}
+		if tr.Duration > 0 && other.Duration > 0 {
+			durationsSum += durationCompare(tr.Duration, other.Duration)
+			durations++
+		}
+	}
+	if titles > 0 {
+		sum += trackTitlesWeight * titlesSum / float64(titles)
+		weight += trackTitlesWeight
+	}
+	if durations > 0 {
+		sum += trackDurationWeight * durationsSum / float64(durations)
+		weight += trackDurationWeight
+	}
+	return sum / weight, true
+}
+
+// Сравнение наименований треков без учета регистра и окружающих пробелов.
+func compareTrackTitles(title, other string) float64 {
+	return tp.JaroWinklerDistance(
+		strings.ToLower(strings.TrimSpace(title)),
+		strings.ToLower(strings.TrimSpace(other)))
+}
+
+// durationCompare оценивает близость длительностей треков: полное совпадение в пределах
+// DurationTolerance с линейным снижением оценки до нуля при разнице MaxDurationDiff.
+func durationCompare(dur, other intutils.Duration) float64 {
+	diff := math.Abs(float64(dur - other))
+	if diff <= DurationTolerance {
+		return 1.
+	}
+	if diff >= MaxDurationDiff {
+		return 0.
+	}
+	return (MaxDurationDiff - diff) / (MaxDurationDiff - DurationTolerance)
+}
+
+// hasPressingData проверяет наличие в запросе данных, отличающих издания одного мастер-релиза:
+// формата носителя, страны, лейбла или номера в каталоге.
+func hasPressingData(release *md.Release) bool {
+	if release.Country != "" || len(discMedia(release)) > 0 {
+		return true
+	}
+	return release.Publishing != nil && len(release.Publishing.Labels) > 0
+}
+
+// Наименования форматов Discogs для фильтра списка версий мастер-релиза.
+var discogsFormats = map[md.Media]string{
+	md.MediaLP:      "Vinyl",
+	md.MediaCD:      "CD",
+	md.MediaSACD:    "SACD",
+	md.MediaReeL:    "Reel-To-Reel",
+	md.MediaDigital: "File",
+}
+
+// pressingFilter составляет фильтр списка версий мастер-релиза по формату (если в запросе
+// указан единственный носитель) и стране издания запрошенного релиза.
+func pressingFilter(release *md.Release) *VersionFilter {
+	filter := &VersionFilter{Country: release.Country}
+	if media := discMedia(release); len(media) == 1 {
+		for m := range media {
+			filter.Format = discogsFormats[m]
+		}
+	}
+	if *filter == (VersionFilter{}) {
+		return nil
+	}
+	return filter
+}
+
+// versionCompare оценивает сходство версии мастер-релиза с запрошенным релизом по данным
+// издания. Учитываются только поля, указанные в запросе.
+func versionCompare(release *md.Release, v *MasterVersion) float64 {
+	var sum, weight float64
+	if media := discMedia(release); len(media) > 0 {
+		weight++
+		matched := media[md.DecodeMedia(v.Format)]
+		for _, format := range v.MajorFormats {
+			matched = matched || media[md.DecodeMedia(format)]
+		}
+		if matched {
+			sum++
+		}
+	}
+	if release.Country != "" {
+		weight++
+		if strings.EqualFold(release.Country, v.Country) {
+			sum++
+		}
+	}
+	if release.Year != 0 && v.Year != 0 {
+		weight++
+		if release.Year == v.Year {
+			sum++
+		}
+	}
+	if release.Publishing != nil && len(release.Publishing.Labels) > 0 {
+		var max float64
+		for _, lbl := range release.Publishing.Labels {
+			if res := labelVersionCompare(lbl, v); res > max {
+				max = res
+			}
+		}
+		weight++
+		sum += max
+	}
+	if weight == 0 {
+		return 0.
+	}
+	return sum / weight
+}
+
+func labelVersionCompare(lbl *md.Label, v *MasterVersion) float64 {
+	var sum, weight float64
+	if lbl.Label != "" {
+		weight++
+		sum += compareNames(lbl.Label, v.Label)
+	}
+	if lbl.Catno != "" {
+		weight++
+		if normalizeCatno(lbl.Catno) == normalizeCatno(v.Catno) {
+			sum++
+		}
+	}
+	if weight == 0 {
+		return 0.
+	}
+	return sum / weight
+}
+
+// Форматы носителей запрошенного релиза.
+func discMedia(release *md.Release) map[md.Media]bool {
+	media := map[md.Media]bool{}
+	for _, disc := range release.Discs {
+		if disc.Format != nil && disc.Format.Media != 0 {
+			media[disc.Format.Media] = true
+		}
+	}
+	return media
+}
@@ -0,0 +1,71 @@
+package discogs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+	intutils "github.com/ytsiuryn/go-intutils"
+)
+
+func newTracklistRelease(tracks ...string) *md.Release {
+	r := md.NewRelease()
+	for i := 0; i < len(tracks); i += 2 {
+		track := md.NewTrack()
+		track.SetTitle(tracks[i])
+		track.Duration = intutils.NewDurationFromString(tracks[i+1])
+		r.Tracks = append(r.Tracks, track)
+	}
+	return r
+}
+
+func TestTracklistCompare(t *testing.T) {
+	request := newTracklistRelease("Speak To Me", "1:13", "Breathe", "2:43", "Money", "6:22")
+	original := newTracklistRelease("Speak To Me", "1:14", "Breathe", "2:44", "Money", "6:23")
+	remaster := newTracklistRelease("Speak To Me", "1:30", "Breathe", "2:49", "Money", "6:30")
+	bonus := newTracklistRelease(
+		"Speak To Me", "1:13", "Breathe", "2:43", "Money", "6:22", "Money (Demo)", "5:30")
+
+	score, ok := tracklistCompare(request, original)
+	assert.True(t, ok)
+	assert.Equal(t, 1., score)
+
+	remasterScore, _ := tracklistCompare(request, remaster)
+	bonusScore, _ := tracklistCompare(request, bonus)
+	assert.Less(t, remasterScore, score)
+	assert.Less(t, bonusScore, score)
+
+	// наименования треков сравниваются без учета регистра
+	tagged := newTracklistRelease("speak to me ", "1:13", "BREATHE", "2:43", "MONEY", "6:22")
+	score, ok = tracklistCompare(request, tagged)
+	assert.True(t, ok)
+	assert.Equal(t, 1., score)
+
+	_, ok = tracklistCompare(request, md.NewRelease())
+	assert.False(t, ok)
+}
+
+func TestDurationCompare(t *testing.T) {
+	assert.Equal(t, 1., durationCompare(60000, 62000))
+	assert.Equal(t, .5, durationCompare(60000, 66000))
+	assert.Equal(t, 0., durationCompare(60000, 75000))
+}
+
+func TestVersionCompare(t *testing.T) {
+	request := md.NewRelease()
+	request.Country = "UK"
+	request.Disc(1).Format.Media = md.MediaLP
+	request.Publishing.AddLabel(md.NewLabel("Harvest", "SHVL 804"))
+	assert.True(t, hasPressingData(request))
+	assert.False(t, hasPressingData(md.NewRelease()))
+	assert.Equal(t, &VersionFilter{Format: "Vinyl", Country: "UK"}, pressingFilter(request))
+	assert.Nil(t, pressingFilter(md.NewRelease()))
+
+	original := &MasterVersion{
+		MajorFormats: []string{"Vinyl"}, Country: "UK", Label: "Harvest", Catno: "SHVL 804"}
+	cd := &MasterVersion{
+		MajorFormats: []string{"CD"}, Country: "US", Label: "Harvest", Catno: "CDP 7 46001 2"}
+	assert.Equal(t, 1., versionCompare(request, original))
+	assert.Less(t, versionCompare(request, cd), MinVersionResult)
+}
@@ -1,9 +1,11 @@
 package discogs
 
 import (
-	"log"
+	"encoding/json"
+	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
 
 	md "github.com/ytsiuryn/ds-audiomd"
 	collection "github.com/ytsiuryn/go-collection"
@@ -11,6 +13,9 @@ import (
 	tp "github.com/ytsiuryn/go-stringutils"
 )
 
+// UnprocessedSeparator separates the values of the same key in the release unprocessed data.
+const UnprocessedSeparator = "; "
+
 type label struct {
 	Name           string `json:"name"`
 	EntityType     string `json:"entity_type"`
@@ -48,10 +53,11 @@ type track struct {
 	ExtraArtists []artist `json:"extraartists"`
 }
 
-// type identifier struct {
-// 	Type  string `json:"type"`
-// 	Value string `json:"value"`
-// }
+type identifier struct {
+	Type        string `json:"type"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+}
 
 type format struct {
 	Descriptions []string `json:"descriptions"`
@@ -60,16 +66,15 @@ type format struct {
 	Text         string   `json:"text"`
 }
 
-// TODO: new structure, research it
-// type company struct {
-// 	Name           string `json:"name"`
-// 	EntityType     string `json:"entity_type"`
-// 	ThumbnailURL   string `json:"thumbnail_url"`
-// 	Catno          string `json:"catno"`
-// 	ResourceURL    string `json:"resource_url"`
-// 	ID             int32  `json:"id"`
-// 	EntityTypeName string `json:"entity_type_name"`
-// }
+type company struct {
+	Name           string `json:"name"`
+	EntityType     string `json:"entity_type"`
+	ThumbnailURL   string `json:"thumbnail_url"`
+	Catno          string `json:"catno"`
+	ResourceURL    string `json:"resource_url"`
+	ID             int32  `json:"id"`
+	EntityTypeName string `json:"entity_type_name"`
+}
 
 type serie struct {
 	Name           string `json:"name"`
@@ -96,18 +101,19 @@ type releaseInfo struct {
 	Title    string `json:"title"`
 	MasterID int32  `json:"master_id"`
 	// ReleasedFormatted string   `json:"released_formatted"`
-	EstimatedWeight int16    `json:"estimated_weight"`
-	MasterURL       string   `json:"master_url"`
-	Released        string   `json:"released"`
-	Tracklist       []track  `json:"tracklist"`
-	ExtraArtists    []artist `json:"extraartists"`
-	Country         string   `json:"country"`
-	Notes           string   `json:"notes"`
-	// Companies   []company `json:"companies"` - список вовлеченных в производство релиза компаний
-	URL         string   `json:"uri"`
-	Formats     []format `json:"formats"`
-	ResourceURL string   `json:"resource_url"`
-	MainRelease int32    `json:"main_release"`
+	EstimatedWeight int16        `json:"estimated_weight"`
+	MasterURL       string       `json:"master_url"`
+	Released        string       `json:"released"`
+	Tracklist       []track      `json:"tracklist"`
+	ExtraArtists    []artist     `json:"extraartists"`
+	Country         string       `json:"country"`
+	Notes           string       `json:"notes"`
+	Companies       []company    `json:"companies"`
+	Identifiers     []identifier `json:"identifiers"`
+	URL             string       `json:"uri"`
+	Formats         []format     `json:"formats"`
+	ResourceURL     string       `json:"resource_url"`
+	MainRelease     int32        `json:"main_release"`
 }
 
 type searchResult struct {
@@ -149,9 +155,102 @@ type masterInfo struct {
 	Notes                string   `json:"notes"`
 }
 
+type versionInfo struct {
+	ID           int32    `json:"id"`
+	Title        string   `json:"title"`
+	Label        string   `json:"label"`
+	Catno        string   `json:"catno"`
+	Country      string   `json:"country"`
+	Format       string   `json:"format"`
+	MajorFormats []string `json:"major_formats"`
+	Released     string   `json:"released"`
+	Status       string   `json:"status"`
+	ResourceURL  string   `json:"resource_url"`
+	Thumb        string   `json:"thumb"`
+}
+
+// versionsResponse is the paged master versions list response.
+type versionsResponse struct {
+	Pagination pagination    `json:"pagination"`
+	Versions   []versionInfo `json:"versions"`
+}
+
+type artistRef struct {
+	ID          int32  `json:"id"`
+	Name        string `json:"name"`
+	Active      bool   `json:"active"`
+	ResourceURL string `json:"resource_url"`
+}
+
+// artistInfo is the structure for json artist info conversion.
+type artistInfo struct {
+	ID             int32       `json:"id"`
+	Name           string      `json:"name"`
+	RealName       string      `json:"realname"`
+	Profile        string      `json:"profile"`
+	URLs           []string    `json:"urls"`
+	NameVariations []string    `json:"namevariations"`
+	Aliases        []artistRef `json:"aliases"`
+	Members        []artistRef `json:"members"`
+	Groups         []artistRef `json:"groups"`
+	Images         []image     `json:"images"`
+	ResourceURL    string      `json:"resource_url"`
+	URI            string      `json:"uri"`
+	ReleasesURL    string      `json:"releases_url"`
+	DataQuality    string      `json:"data_quality"`
+}
+
+type labelRef struct {
+	ID          int32  `json:"id"`
+	Name        string `json:"name"`
+	ResourceURL string `json:"resource_url"`
+}
+
+// labelInfo is the structure for json label info conversion.
+type labelInfo struct {
+	ID          int32      `json:"id"`
+	Name        string     `json:"name"`
+	Profile     string     `json:"profile"`
+	ContactInfo string     `json:"contact_info"`
+	ParentLabel *labelRef  `json:"parent_label"`
+	Sublabels   []labelRef `json:"sublabels"`
+	URLs        []string   `json:"urls"`
+	Images      []image    `json:"images"`
+	ResourceURL string     `json:"resource_url"`
+	URI         string     `json:"uri"`
+	ReleasesURL string     `json:"releases_url"`
+	DataQuality string     `json:"data_quality"`
+}
+
+type pagination struct {
+	Page    int `json:"page"`
+	Pages   int `json:"pages"`
+	PerPage int `json:"per_page"`
+	Items   int `json:"items"`
+}
+
+type labelReleaseInfo struct {
+	ID          int32  `json:"id"`
+	Status      string `json:"status"`
+	Format      string `json:"format"`
+	Catno       string `json:"catno"`
+	Thumb       string `json:"thumb"`
+	ResourceURL string `json:"resource_url"`
+	Title       string `json:"title"`
+	Year        int32  `json:"year"`
+	Artist      string `json:"artist"`
+}
+
+// labelReleasesResponse is the paged label releases list response.
+type labelReleasesResponse struct {
+	Pagination pagination         `json:"pagination"`
+	Releases   []labelReleaseInfo `json:"releases"`
+}
+
 // searchResponse is the search master list response.
 type searchResponse struct {
-	Results []searchResult `json:"results"`
+	Pagination pagination     `json:"pagination"`
+	Results    []searchResult `json:"results"`
 }
 
 // Search gatheres the common release info results.
@@ -160,7 +259,13 @@ func (sr *searchResponse) Search() []*md.Release {
 	for _, result := range sr.Results {
 		r := md.NewRelease()
 		r.IDs[md.DiscogsReleaseID] = strconv.Itoa(int(result.ID))
+		// Discogs search result titles have the "Artist - Title" form: the artist is split off
+		// so that preliminary scoring compares titles and performers separately.
 		r.Title = result.Title
+		if i := strings.Index(result.Title, " - "); i > 0 {
+			r.ActorRoles.Add(trimNameIndex(result.Title[:i]), "performer")
+			r.Title = result.Title[i+3:]
+		}
 		r.Year = tp.NaiveStringToInt(result.Year)
 		for _, lblName := range result.Label {
 			r.Publishing.Labels = append(
@@ -176,12 +281,82 @@ func (sr *searchResponse) Search() []*md.Release {
 	return releases
 }
 
+// HasBarcode checks the search result contains the normalized barcode.
+func (result *searchResult) HasBarcode(barcode string) bool {
+	for _, b := range result.Barcode {
+		if normalized, ok := NormalizeBarcode(b); ok && normalized == barcode {
+			return true
+		}
+	}
+	return false
+}
+
+// HasLabel checks the search result catalog number and label name (if present) matches the label.
+// Label names match when their similarity exceeds minScore.
+func (result *searchResult) HasLabel(lbl *md.Label, minScore float64) bool {
+	if normalizeCatno(result.CatNo) != normalizeCatno(lbl.Catno) {
+		return false
+	}
+	if lbl.Label == "" {
+		return true
+	}
+	for _, name := range result.Label {
+		if compareNames(lbl.Label, name) > minScore {
+			return true
+		}
+	}
+	return false
+}
+
 // Master updates release with master page data.
 func (mi *masterInfo) Master(r *md.Release) {
 	r.Original.Year = int(mi.Year)
 	r.Original.Notes = mi.Notes
 }
 
+// Release converts master data to common release format.
+func (mi *masterInfo) Release() *md.Release {
+	r := md.NewRelease()
+	r.Title = mi.Title
+	r.Year = int(mi.Year)
+	r.Notes = mi.Notes
+	r.IDs[md.DiscogsMasterID] = strconv.Itoa(int(mi.ID))
+	genres := append(mi.Genres, mi.Styles...)
+	for _, artist := range mi.Artists {
+		artist.ReleaseActor(r)
+	}
+	for _, tr := range mi.Tracklist {
+		track := tr.Track()
+		track.LinkWithDisc(r.Disc(md.DiscNumberByTrackPos(track.Position)))
+		track.Record.Genres = append(track.Record.Genres, genres...)
+		r.Tracks = append(r.Tracks, track)
+		r.TotalTracks++
+	}
+	var pia *md.PictureInAudio
+	for _, img := range mi.Images {
+		if pia = img.Cover(); pia != nil {
+			r.Pictures = append(r.Pictures, pia)
+		}
+	}
+	return r
+}
+
+// Version converts data to the master version description.
+func (vi *versionInfo) Version() *MasterVersion {
+	return &MasterVersion{
+		Title:        vi.Title,
+		Format:       vi.Format,
+		MajorFormats: vi.MajorFormats,
+		Label:        vi.Label,
+		Catno:        vi.Catno,
+		Country:      vi.Country,
+		Released:     vi.Released,
+		Year:         tp.NaiveStringToInt(vi.Released),
+		Status:       vi.Status,
+		IDs:          md.ReleaseIDs{md.DiscogsReleaseID: strconv.Itoa(int(vi.ID))},
+	}
+}
+
 // Release converts data to common release format.
 func (ai *releaseInfo) Release(r *md.Release) {
 	r.Title = ai.Title
@@ -204,13 +379,13 @@ func (ai *releaseInfo) Release(r *md.Release) {
 		r.TotalTracks++
 	}
 	for _, artist := range ai.ExtraArtists {
-		if positions := artist.TrackPositions(); len(positions) > 0 {
-			for _, pos := range positions {
-				if tr := r.TrackByPosition(pos); tr != nil {
-					artist.TrackActor(tr)
-					tr.Record.Genres = append(tr.Record.Genres, genres...)
-				}
-			}
+		if artist.Tracks == "" {
+			artist.ReleaseCredit(r)
+			continue
+		}
+		for _, tr := range artist.CreditedTracks(r) {
+			artist.TrackActor(tr)
+			tr.Record.Genres = append(tr.Record.Genres, genres...)
 		}
 	}
 	r.Publishing = md.NewPublishing()
@@ -218,6 +393,12 @@ func (ai *releaseInfo) Release(r *md.Release) {
 		lbl := lbl.NewLabel()
 		r.Publishing.Labels = append(r.Publishing.Labels, lbl)
 	}
+	for _, c := range ai.Companies {
+		c.Publish(r)
+	}
+	for _, id := range ai.Identifiers {
+		id.Identify(r)
+	}
 	for i, fmt := range ai.Formats {
 		r.ReleaseType.DecodeSlice(&fmt.Descriptions)
 		r.ReleaseStatus.DecodeSlice(&fmt.Descriptions)
@@ -231,34 +412,171 @@ func (ai *releaseInfo) Release(r *md.Release) {
 	var pia *md.PictureInAudio
 	for _, img := range ai.Images {
 		if pia = img.Cover(); pia != nil {
-			r.Pictures = append(r.Pictures)
+			r.Pictures = append(r.Pictures, pia)
 		}
 	}
 }
 
+// Artist converts data to the artist profile.
+func (ai *artistInfo) Artist() *ArtistProfile {
+	artist := &ArtistProfile{
+		Name:           ai.Name,
+		RealName:       ai.RealName,
+		Profile:        ai.Profile,
+		NameVariations: ai.NameVariations,
+		URLs:           ai.URLs,
+		IDs:            md.ActorIDs{md.DiscogsArtistID: strconv.Itoa(int(ai.ID))},
+	}
+	for _, ref := range ai.Aliases {
+		artist.Aliases = append(artist.Aliases, ref.Link())
+	}
+	for _, ref := range ai.Members {
+		artist.Members = append(artist.Members, ref.Link())
+	}
+	for _, ref := range ai.Groups {
+		artist.Groups = append(artist.Groups, ref.Link())
+	}
+	for _, img := range ai.Images {
+		artist.Pictures = append(artist.Pictures, img.Picture(md.PictTypeArtist))
+	}
+	return artist
+}
+
+// Label converts data to the label profile.
+func (li *labelInfo) Label() *LabelProfile {
+	lbl := &LabelProfile{
+		Name:        li.Name,
+		Profile:     li.Profile,
+		ContactInfo: li.ContactInfo,
+		URLs:        li.URLs,
+		IDs:         md.LabelIDs{md.DiscogsLabelID: strconv.Itoa(int(li.ID))},
+	}
+	if li.ParentLabel != nil {
+		lbl.ParentLabel = li.ParentLabel.Link()
+	}
+	for _, ref := range li.Sublabels {
+		lbl.Sublabels = append(lbl.Sublabels, ref.Link())
+	}
+	for _, img := range li.Images {
+		lbl.Pictures = append(lbl.Pictures, img.Picture(md.PictTypePublisherLogotype))
+	}
+	return lbl
+}
+
+// LabelReleases updates label profile with the page of label releases.
+func (lr *labelReleasesResponse) LabelReleases(lbl *LabelProfile) {
+	lbl.Pagination = lr.Pagination.Pagination()
+	for _, r := range lr.Releases {
+		lbl.Releases = append(
+			lbl.Releases,
+			&LabelRelease{
+				Title:  r.Title,
+				Artist: r.Artist,
+				Format: r.Format,
+				Catno:  r.Catno,
+				Status: r.Status,
+				Year:   int(r.Year),
+				IDs:    md.ReleaseIDs{md.DiscogsReleaseID: strconv.Itoa(int(r.ID))},
+			})
+	}
+}
+
+func (ref *labelRef) Link() *LabelLink {
+	return &LabelLink{
+		Name: ref.Name,
+		IDs:  md.LabelIDs{md.DiscogsLabelID: strconv.Itoa(int(ref.ID))},
+	}
+}
+
+func (p *pagination) Pagination() *Pagination {
+	return &Pagination{Page: p.Page, Pages: p.Pages, PerPage: p.PerPage, Items: p.Items}
+}
+
+func (ref *artistRef) Link() *ArtistLink {
+	return &ArtistLink{
+		Name:   ref.Name,
+		Active: ref.Active,
+		IDs:    md.ActorIDs{md.DiscogsArtistID: strconv.Itoa(int(ref.ID))},
+	}
+}
+
 func (a *artist) TrackPositions() []string {
 	positions := collection.SplitWithTrim(a.Tracks, ",")
 	return positions
 }
 
+// CreditedTracks returns the release tracks the artist is credited for.
+// Positions may be enumerated ("A1, A3") or set by ranges ("A1 to B2").
+func (a *artist) CreditedTracks(r *md.Release) []*md.Track {
+	var tracks []*md.Track
+	for _, pos := range a.TrackPositions() {
+		if bounds := strings.SplitN(pos, " to ", 2); len(bounds) == 2 {
+			tracks = append(tracks, tracksInRange(r, bounds[0], bounds[1])...)
+		} else if tr := r.TrackByPosition(md.NormalizePosition(pos)); tr != nil {
+			tracks = append(tracks, tr)
+		}
+	}
+	return tracks
+}
+
+func tracksInRange(r *md.Release, from, to string) []*md.Track {
+	var tracks []*md.Track
+	from, to = md.NormalizePosition(strings.TrimSpace(from)), md.NormalizePosition(strings.TrimSpace(to))
+	inRange := false
+	for _, tr := range r.Tracks {
+		if tr.Position == from {
+			inRange = true
+		}
+		if inRange {
+			tracks = append(tracks, tr)
+		}
+		if tr.Position == to {
+			break
+		}
+	}
+	return tracks
+}
+
+// ReleaseActor adds the main release artist. Artists without role are the performers.
 func (a *artist) ReleaseActor(r *md.Release) {
 	if a.Role == "" {
 		r.ActorRoles.Add(a.Name, "performer")
 	} else {
-		// TODO: РЕАЛиЗОВАТЬ!
-		log.Panicf("НЕ РЕАЛИЗОВАНО ДЛЯ НЕ ПЕРФОРМЕРОВ: %s", a.Role)
-		// actors := ActorsByRole(track, a.Role)
-		// actor := actors.AddRole(a.Name, a.Role)
-		// actor.IDs.Add(ServiceName, strconv.Itoa(int(a.ID)))
+		for _, c := range parseRoles(a.Role) {
+			r.ActorRoles.Add(a.Name, c.String())
+		}
+	}
+	r.Actors.Add(a.Name, md.DiscogsArtistID, strconv.Itoa(int(a.ID)))
+}
+
+// ReleaseCredit adds the release credit that is not bound to the certain tracks.
+// Release level roles are stored in the release, other roles are related to every track.
+func (a *artist) ReleaseCredit(r *md.Release) {
+	for _, c := range parseRoles(a.Role) {
+		if c.Target() == releaseRole {
+			r.ActorRoles.Add(a.Name, c.String())
+			r.Actors.Add(a.Name, md.DiscogsArtistID, strconv.Itoa(int(a.ID)))
+			continue
+		}
+		for _, track := range r.Tracks {
+			a.trackCredit(track, c)
+		}
 	}
 }
 
 func (a *artist) TrackActor(track *md.Track) {
 	if a.Role == "" {
 		track.Record.ActorRoles.Add(a.Name, "performer")
-	} else {
-		ActorsByRole(track, a.Role).Add(a.Name, a.Role)
+		track.Actors.Add(a.Name, md.DiscogsArtistID, strconv.Itoa(int(a.ID)))
+		return
+	}
+	for _, c := range parseRoles(a.Role) {
+		a.trackCredit(track, c)
 	}
+}
+
+func (a *artist) trackCredit(track *md.Track, c credit) {
+	creditActors(track, c).Add(a.Name, c.String())
 	track.Actors.Add(a.Name, md.DiscogsArtistID, strconv.Itoa(int(a.ID)))
 }
 
@@ -275,11 +593,9 @@ func (tr *track) Track() *md.Track {
 			track.Duration = intutils.NewDurationFromString(sTrack.Duration)
 			for _, artist := range tr.ExtraArtists {
 				artist.TrackActor(track)
-				track.Record.ActorRoles.Add(artist.Name, artist.Role)
 			}
 			for _, artist := range sTrack.ExtraArtists {
 				artist.TrackActor(track)
-				track.Record.ActorRoles.Add(artist.Name, artist.Role)
 			}
 		}
 	} else {
@@ -288,7 +604,6 @@ func (tr *track) Track() *md.Track {
 		track.Duration = intutils.NewDurationFromString(tr.Duration)
 		for _, artist := range tr.ExtraArtists {
 			artist.TrackActor(track)
-			track.Record.ActorRoles.Add(artist.Name, artist.Role)
 		}
 	}
 	return track
@@ -300,6 +615,88 @@ func (lbl *label) NewLabel() *md.Label {
 	return ret
 }
 
+// Publish stores the company involved in the release production (pressing plant, copyright
+// holder, studio etc.) in the release unprocessed data as a single JSON-encoded Company value.
+// See CompanyKeyPrefix for the key scheme. The roles and catalog numbers of the company listed
+// several times are merged.
+func (c *company) Publish(r *md.Release) {
+	role := unprocessedKey(c.EntityTypeName)
+	if role == "" || c.Name == "" {
+		return
+	}
+	key := CompanyKeyPrefix + unprocessedKey(c.Name)
+	if c.ID != 0 {
+		key = CompanyKeyPrefix + strconv.Itoa(int(c.ID))
+	}
+	stored := Company{Name: c.Name}
+	if data, ok := r.Unprocessed[key]; ok {
+		if err := json.Unmarshal([]byte(data), &stored); err != nil {
+			return
+		}
+	} else if c.ID != 0 {
+		stored.IDs = md.LabelIDs{md.DiscogsLabelID: strconv.Itoa(int(c.ID))}
+	}
+	if !collection.ContainsStr(role, stored.Roles) {
+		stored.Roles = append(stored.Roles, role)
+	}
+	if c.Catno != "" && !collection.ContainsStr(c.Catno, stored.Catnos) {
+		stored.Catnos = append(stored.Catnos, c.Catno)
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return
+	}
+	r.Unprocessed[key] = string(data)
+}
+
+// Identify stores the release identifier. The first barcode and ASIN are stored in the release
+// IDs, other identifiers are kept in the release unprocessed data. Matrix/runout values are
+// grouped by the side or disc mentioned in the description: "matrix_runout_side_a",
+// "matrix_runout_disc_2".
+func (id *identifier) Identify(r *md.Release) {
+	value := strings.TrimSpace(id.Value)
+	if value == "" {
+		return
+	}
+	key := unprocessedKey(id.Type)
+	switch key {
+	case "barcode":
+		if r.Publishing.IDs[md.PublishingBarcode] == "" {
+			r.Publishing.IDs[md.PublishingBarcode] = value
+			return
+		}
+	case "asin":
+		if r.IDs[md.Asin] == "" {
+			r.IDs[md.Asin] = value
+			return
+		}
+	}
+	if key == "matrix_runout" {
+		key += matrixLocation(id.Description)
+	} else if id.Description != "" {
+		value += " (" + id.Description + ")"
+	}
+	if !hasUnprocessed(r.Unprocessed, key, value) {
+		appendUnprocessed(r.Unprocessed, key, value)
+	}
+}
+
+var (
+	sideRe = regexp.MustCompile(`(?i)\bside\s+([a-z])\b|\b([a-z])[\s-]side\b`)
+	discRe = regexp.MustCompile(`(?i)\b(?:disc|disk|cd|lp)\s*(\d+)\b`)
+)
+
+// matrixLocation defines the key suffix for the side or disc mentioned in the description.
+func matrixLocation(description string) string {
+	if m := sideRe.FindStringSubmatch(description); m != nil {
+		return "_side_" + strings.ToLower(m[1]+m[2])
+	}
+	if m := discRe.FindStringSubmatch(description); m != nil {
+		return "_disc_" + m[1]
+	}
+	return ""
+}
+
 func (fmt *format) DiscFormat() *md.DiscFormat {
 	return &md.DiscFormat{
 		Media: md.DecodeMedia(fmt.Name),
@@ -315,19 +712,56 @@ func (img *image) Cover() *md.PictureInAudio {
 	return nil
 }
 
-// ActorsByRole определяет коллекцию для размещения описания по наименованию роли.
-func ActorsByRole(track *md.Track, roles string) *md.ActorRoles {
-	var ret *md.ActorRoles
-	if strings.Contains(roles, "Artwork") ||
-		strings.Contains(roles, "Design") ||
-		strings.Contains(roles, "Photography") {
-		ret = &track.ActorRoles
-	} else if strings.Contains(roles, "Composer") || // TODO: проверить!
-		strings.Contains(roles, "Lyricist") ||
-		strings.Contains(roles, "Written-By") {
-		ret = &track.Composition.ActorRoles
+func (img *image) Picture(pictType md.PictType) *md.PictureInAudio {
+	return &md.PictureInAudio{
+		PictureMetadata: &md.PictureMetadata{
+			Width:  uint32(img.Width),
+			Height: uint32(img.Height),
+		},
+		PictType: pictType,
+		CoverURL: img.URI,
+	}
+}
+
+// unprocessedKey converts the Discogs type name to the snake case key without parenthesized
+// notes: "Phonographic Copyright (p)" -> "phonographic_copyright".
+func unprocessedKey(name string) string {
+	var sb strings.Builder
+	depth := 0
+	underscore := false
+	for _, ch := range strings.ToLower(name) {
+		switch {
+		case ch == '(':
+			depth++
+		case ch == ')':
+			if depth > 0 {
+				depth--
+			}
+		case depth > 0:
+		case unicode.IsLetter(ch) || unicode.IsDigit(ch):
+			if underscore && sb.Len() > 0 {
+				sb.WriteByte('_')
+			}
+			underscore = false
+			sb.WriteRune(ch)
+		default:
+			underscore = true
+		}
+	}
+	return sb.String()
+}
+
+// appendUnprocessed adds the value to the list of values stored under the key.
+func appendUnprocessed(m collection.StrMap, key, val string) {
+	if old, ok := m[key]; ok {
+		m[key] = old + UnprocessedSeparator + val
 	} else {
-		ret = &track.Record.ActorRoles
+		m[key] = val
 	}
-	return ret
+}
+
+// hasUnprocessed checks the value is in the list of values stored under the key.
+func hasUnprocessed(m collection.StrMap, key, val string) bool {
+	old, ok := m[key]
+	return ok && collection.ContainsStr(val, strings.Split(old, UnprocessedSeparator))
 }
@@ -0,0 +1,196 @@
+package discogs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+func TestArtistInfo(t *testing.T) {
+	data, err := os.ReadFile("testdata/artist.json")
+	require.NoError(t, err)
+	var ai artistInfo
+	require.NoError(t, json.Unmarshal(data, &ai))
+
+	artist := ai.Artist()
+	assert.Equal(t, "Pink Floyd", artist.Name)
+	assert.Equal(t, "45467", artist.IDs[md.DiscogsArtistID])
+	assert.Equal(t, []string{"Pink Floid", "The Pink Floyd"}, artist.NameVariations)
+	require.Len(t, artist.Members, 3)
+	assert.Equal(t, "David Gilmour", artist.Members[0].Name)
+	assert.True(t, artist.Members[0].Active)
+	assert.Equal(t, "2729093", artist.Aliases[0].IDs[md.DiscogsArtistID])
+	require.Len(t, artist.Pictures, 2)
+	assert.Equal(t, md.PictTypeArtist, artist.Pictures[0].PictType)
+}
+
+func TestCompareArtistNames(t *testing.T) {
+	assert.Equal(t, 1., compareNames("John Smith", "John Smith (2)"))
+	assert.Equal(t, 1., compareNames("pink floyd", "Pink Floyd"))
+	assert.Equal(t, "Band (Live)", trimNameIndex("Band (Live)"))
+}
+
+func TestLabelInfo(t *testing.T) {
+	data, err := os.ReadFile("testdata/label.json")
+	require.NoError(t, err)
+	var li labelInfo
+	require.NoError(t, json.Unmarshal(data, &li))
+
+	lbl := li.Label()
+	assert.Equal(t, "Harvest", lbl.Name)
+	assert.Equal(t, "2", lbl.IDs[md.DiscogsLabelID])
+	assert.Equal(t, "EMI", lbl.ParentLabel.Name)
+	assert.Equal(t, "38386", lbl.Sublabels[0].IDs[md.DiscogsLabelID])
+	assert.Equal(t, md.PictTypePublisherLogotype, lbl.Pictures[0].PictType)
+
+	data, err = os.ReadFile("testdata/label_releases.json")
+	require.NoError(t, err)
+	var lr labelReleasesResponse
+	require.NoError(t, json.Unmarshal(data, &lr))
+
+	lr.LabelReleases(lbl)
+	assert.Equal(t, &Pagination{Page: 2, Pages: 120, PerPage: 2, Items: 240}, lbl.Pagination)
+	require.Len(t, lbl.Releases, 2)
+	assert.Equal(t, "SHVL 804", lbl.Releases[0].Catno)
+	assert.Equal(t, "1873013", lbl.Releases[0].IDs[md.DiscogsReleaseID])
+}
+
+func TestMasterInfo(t *testing.T) {
+	data, err := os.ReadFile("testdata/master.json")
+	require.NoError(t, err)
+	var mi masterInfo
+	require.NoError(t, json.Unmarshal(data, &mi))
+
+	r := mi.Release()
+	assert.Equal(t, "The Dark Side Of The Moon", r.Title)
+	assert.Equal(t, 1973, r.Year)
+	assert.Equal(t, "10362", r.IDs[md.DiscogsMasterID])
+	assert.Equal(t, 3, r.TotalTracks)
+	assert.Len(t, r.Discs, 1)
+	assert.Contains(t, r.ActorRoles.Filter(md.IsPerformer), "Pink Floyd")
+}
+
+func TestSearchResponse(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", searchFixture+".json"))
+	require.NoError(t, err)
+	var sr searchResponse
+	require.NoError(t, json.Unmarshal(data, &sr))
+
+	releases := sr.Search()
+	require.Len(t, releases, 3)
+	r := releases[0]
+	assert.Equal(t, "4139588", r.IDs[md.DiscogsReleaseID])
+	assert.Equal(t, "The Dark Side Of The Moon", r.Title)
+	assert.Equal(t, []string{"performer"}, r.ActorRoles["Pink Floyd"])
+	assert.Equal(t, 1977, r.Year)
+	require.Len(t, r.Publishing.Labels, 2)
+	assert.Equal(t, "SHVL 804", r.Publishing.Labels[0].Catno)
+	// номер омонима Discogs в имени исполнителя не сохраняется
+	assert.Contains(t, releases[2].ActorRoles, md.ActorName("Pink Floyd"))
+
+	sr = searchResponse{Results: []searchResult{{ID: 1, Title: "Untitled"}}}
+	r = sr.Search()[0]
+	assert.Equal(t, "Untitled", r.Title)
+	assert.Empty(t, r.ActorRoles)
+}
+
+func TestVersionFilter(t *testing.T) {
+	data, err := os.ReadFile("testdata/versions.json")
+	require.NoError(t, err)
+	var vr versionsResponse
+	require.NoError(t, json.Unmarshal(data, &vr))
+
+	var versions []*MasterVersion
+	for _, vi := range vr.Versions {
+		versions = append(versions, vi.Version())
+	}
+	assert.Equal(t, 1977, versions[1].Year)
+
+	count := func(filter *VersionFilter) (n int) {
+		for _, v := range versions {
+			if filter.Match(v) {
+				n++
+			}
+		}
+		return
+	}
+	assert.Equal(t, 3, count(nil))
+	assert.Equal(t, 2, count(&VersionFilter{Format: "vinyl"}))
+	assert.Equal(t, 1, count(&VersionFilter{Format: "Gatefold"}))
+	assert.Equal(t, 1, count(&VersionFilter{Country: "uk", Year: 1977}))
+	assert.Equal(t, 0, count(&VersionFilter{Format: "CD", Country: "UK"}))
+}
+
+func TestReleaseCompanies(t *testing.T) {
+	data, err := os.ReadFile("testdata/release.json")
+	require.NoError(t, err)
+	var ri releaseInfo
+	require.NoError(t, json.Unmarshal(data, &ri))
+
+	r := md.NewRelease()
+	ri.Release(r)
+
+	companies := map[string]*Company{}
+	for _, c := range Companies(r) {
+		companies[c.Name] = c
+	}
+	gramophone := companies["The Gramophone Co. Ltd."]
+	require.NotNil(t, gramophone)
+	assert.ElementsMatch(t, []string{"phonographic_copyright", "record_company"}, gramophone.Roles)
+	assert.Equal(t, "253617", gramophone.IDs[md.DiscogsLabelID])
+	assert.Contains(t, r.Unprocessed, CompanyKeyPrefix+"253617")
+	assert.Equal(t, []string{"record_company"}, companies["EMI Records Ltd."].Roles)
+	assert.True(t, companies["Garrod & Lofthouse Ltd."].HasRole("made_by"))
+	assert.True(t, companies["Abbey Road Studios"].HasRole("recorded_at"))
+
+	// наименование компании с разделителем не нарушает сведения о ней
+	r = md.NewRelease()
+	(&company{ID: 1, Name: "Smith; Jones Ltd.", Catno: "A; B", EntityTypeName: "Made By"}).Publish(r)
+	(&company{ID: 1, Name: "Smith; Jones Ltd.", EntityTypeName: "Distributed By"}).Publish(r)
+	(&company{Name: "Unknown Studio", EntityTypeName: "Recorded At"}).Publish(r)
+	companies = map[string]*Company{}
+	for _, c := range Companies(r) {
+		companies[c.Name] = c
+	}
+	require.Len(t, companies, 2)
+	assert.Equal(t, []string{"made_by", "distributed_by"}, companies["Smith; Jones Ltd."].Roles)
+	assert.Equal(t, []string{"A; B"}, companies["Smith; Jones Ltd."].Catnos)
+	assert.Empty(t, companies["Unknown Studio"].IDs)
+	assert.Contains(t, r.Unprocessed, CompanyKeyPrefix+"unknown_studio")
+	assert.Equal(t, "copyright", unprocessedKey("Copyright (c)"))
+	assert.Equal(t, "lacquer_cut_at", unprocessedKey("Lacquer Cut At"))
+}
+
+func TestReleaseIdentifiers(t *testing.T) {
+	data, err := os.ReadFile("testdata/release.json")
+	require.NoError(t, err)
+	var ri releaseInfo
+	require.NoError(t, json.Unmarshal(data, &ri))
+
+	ri.Identifiers = append(
+		ri.Identifiers,
+		identifier{Type: "Barcode", Value: "5 099902 987613"},
+		identifier{Type: "Barcode", Value: "5099902987613", Description: "Text"},
+		identifier{Type: "Label Code", Value: "LC 0542"},
+		identifier{Type: "Rights Society", Value: "GEMA"},
+		identifier{Type: "Matrix / Runout", Value: "CDP 7 46001 2 @ 1", Description: "Disc 2"},
+	)
+	r := md.NewRelease()
+	ri.Release(r)
+
+	assert.Equal(t, "5 099902 987613", r.Publishing.IDs[md.PublishingBarcode])
+	assert.Equal(t, "5099902987613 (Text)", r.Unprocessed["barcode"])
+	assert.Equal(t, "LC 0542", r.Unprocessed["label_code"])
+	assert.Equal(t, "GEMA", r.Unprocessed["rights_society"])
+	assert.Equal(t, "L 7303 TPS (Printers code (inside gatefold sleeve))", r.Unprocessed["other"])
+	assert.Equal(t, "CDP 7 46001 2 @ 1", r.Unprocessed["matrix_runout_disc_2"])
+	assert.True(t, strings.HasPrefix(r.Unprocessed["matrix_runout_side_a"], "SHVL 804A; SHVL 804 A-9 GOG 2 HTM"))
+	assert.Contains(t, r.Unprocessed["matrix_runout_side_b"], "SHVL 804 B-7  PT  2")
+}
@@ -0,0 +1,214 @@
+package discogs
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+// Стратегии поиска релиза по неполным данным в порядке их применения.
+const (
+	// Запрос по всем известным полям релиза.
+	StrategyStrict = "strict"
+	// Запрос по наименованию и исполнителям без года, лейбла и номера в каталоге.
+	StrategyRelaxed = "relaxed"
+	// Полнотекстовый запрос по наименованию без пометок издания и исполнителям.
+	StrategyFreeText = "free_text"
+	// Запрос по исполнителям и наименованию трека.
+	StrategyArtistTracks = "artist_tracks"
+)
+
+// Наименования исполнителя сборников, не используемые в запросах.
+var variousArtists = []string{"various", "various artists", "va"}
+
+// Слова пометок издания, отделяемых от наименования релиза дефисом, например
+// "Abbey Road - 2009 Remaster".
+var editionWords = []string{
+	"remaster", "deluxe", "edition", "anniversary", "expanded", "bonus", "reissue", "version", "mono", "stereo",
+}
+
+// searchQuery - попытка поиска релиза: параметры запроса и предварительная оценка
+// результатов поиска.
+type searchQuery struct {
+	strategy string
+	params   url.Values
+	score    func(*md.Release) float64
+}
+
+// planQueries составляет последовательность запросов поиска релиза от строгого к наиболее
+// свободному. Запросы, совпадающие с предшествующими, исключаются.
+func planQueries(release *md.Release) []*searchQuery {
+	var queries []*searchQuery
+	planned := map[string]bool{}
+	add := func(strategy string, params url.Values, score func(*md.Release) float64) {
+		if key := params.Encode(); !planned[key] {
+			planned[key] = true
+			queries = append(queries, &searchQuery{strategy: strategy, params: params, score: score})
+		}
+	}
+	compare := func(r *md.Release) float64 { return release.Compare(r) }
+	performers := queryPerformers(release)
+
+	add(StrategyStrict, searchParams(release, "release"), compare)
+
+	relaxed := url.Values{"type": {"release"}, "title": {release.Title}}
+	for _, name := range performers {
+		relaxed.Add("artist", name)
+	}
+	add(StrategyRelaxed, relaxed, compare)
+
+	if title := normalizeTitle(release.Title); title != "" {
+		q := append([]string{title}, performers...)
+		add(StrategyFreeText, url.Values{"type": {"release"}, "q": {strings.Join(q, " ")}}, compare)
+	}
+
+	if track := firstTrackTitle(release); track != "" && len(performers) > 0 {
+		params := url.Values{"type": {"release"}, "track": {track}}
+		for _, name := range performers {
+			params.Add("artist", name)
+		}
+		// результаты отобраны Discogs по наименованию трека, списки треков сравниваются
+		// при окончательной оценке
+		performerRoles := release.ActorRoles.Filter(md.IsPerformer)
+		add(StrategyArtistTracks, params, func(r *md.Release) float64 {
+			return performerRoles.Compare(r.ActorRoles.Filter(md.IsPerformer))
+		})
+	}
+	return queries
+}
+
+// planSearch выполняет запросы поиска релиза в порядке planQueries, пока количество
+// предварительных кандидатов не достигнет opts.MinCandidates. Кандидаты, найденные
+// несколькими запросами, учитываются однократно с лучшей оценкой. Следующий запрос
+// не выполняется, если оставшегося бюджета запросов к Discogs API недостаточно для него
+// и загрузки кандидатов.
+func (d *Discogs) planSearch(release *md.Release, opts *SearchOptions) ([]*md.Suggestion, error) {
+	var suggestions []*md.Suggestion
+	known := map[string]*md.Suggestion{}
+	cost := 0
+	for i, q := range planQueries(release) {
+		if i > 0 && !d.searchBudget(opts) {
+			d.Log.WithField("strategy", q.strategy).
+				WithField("cost", cost).
+				Info("Search is stopped: rate budget is low")
+			break
+		}
+		results, requests, err := d.searchPages(q.params, opts.SearchPages)
+		cost += requests
+		if err != nil {
+			if len(suggestions) == 0 {
+				return nil, err
+			}
+			d.Log.WithField("strategy", q.strategy).WithError(err).Warn("Search attempt failed")
+			break
+		}
+		found := 0
+		for _, r := range results {
+			score := q.score(r)
+			if score <= opts.minShortResult() {
+				continue
+			}
+			id := r.IDs[md.DiscogsReleaseID]
+			if s, ok := known[id]; ok {
+				if score > s.SourceSimilarity {
+					s.SourceSimilarity = score
+				}
+				continue
+			}
+			s := &md.Suggestion{Release: r, ServiceName: ServiceName, SourceSimilarity: score}
+			known[id] = s
+			suggestions = append(suggestions, s)
+			found++
+		}
+		d.Log.WithField("strategy", q.strategy).
+			WithField("query", q.params.Encode()).
+			WithField("results", len(results)).
+			WithField("candidates", found).
+			WithField("cost", requests).
+			Debug("Search attempt")
+		if len(suggestions) >= opts.minCandidates() {
+			break
+		}
+	}
+	d.Log.WithField("candidates", len(suggestions)).WithField("cost", cost).Debug("Search plan completed")
+	return suggestions, nil
+}
+
+// Бюджета запросов к Discogs API достаточно для очередного запроса поиска и загрузки кандидатов.
+func (d *Discogs) searchBudget(opts *SearchOptions) bool {
+	return d.hasBudget(opts.SearchPages + opts.MaxCandidates)
+}
+
+// Оставшегося бюджета запросов к Discogs API достаточно для выполнения `requests` запросов.
+// Источники данных, отличные от Discogs API, не ограничены.
+func (d *Discogs) hasBudget(requests int) bool {
+	if d.backend != d.web {
+		return true
+	}
+	limit, _, remaining := d.api.limiter.Budget()
+	return limit <= 0 || remaining >= requests
+}
+
+// Имена исполнителей релиза, кроме обозначения сборника ("Various").
+func queryPerformers(release *md.Release) []string {
+	var names []string
+	for name := range release.ActorRoles.Filter(md.IsPerformer) {
+		if !isVariousArtists(string(name)) {
+			names = append(names, string(name))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isVariousArtists(name string) bool {
+	for _, various := range variousArtists {
+		if strings.EqualFold(strings.TrimSpace(name), various) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTitle удаляет из наименования релиза пометки в скобках и пометки издания после
+// дефиса: "The Wall (Remastered) [Deluxe]" -> "The Wall".
+func normalizeTitle(title string) string {
+	var sb strings.Builder
+	depth := 0
+	for _, ch := range title {
+		switch ch {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+		default:
+			if depth == 0 {
+				sb.WriteRune(ch)
+			}
+		}
+	}
+	title = sb.String()
+	if i := strings.LastIndex(title, " - "); i > 0 {
+		suffix := strings.ToLower(title[i+3:])
+		for _, word := range editionWords {
+			if strings.Contains(suffix, word) {
+				title = title[:i]
+				break
+			}
+		}
+	}
+	return strings.Join(strings.Fields(title), " ")
+}
+
+func firstTrackTitle(release *md.Release) string {
+	for _, tr := range release.Tracks {
+		if tr.Title != "" {
+			return tr.Title
+		}
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package discogs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	for title, normalized := range map[string]string{
+		"The Wall":                            "The Wall",
+		"The Wall (Remastered) [Deluxe]":      "The Wall",
+		"Abbey Road - 2009 Remaster":          "Abbey Road",
+		"Hello - Goodbye":                     "Hello - Goodbye",
+		"Wish You Were Here (Experience Ed.)": "Wish You Were Here",
+		"(Remastered)":                        "",
+	} {
+		assert.Equal(t, normalized, normalizeTitle(title), title)
+	}
+}
+
+func TestPlanQueries(t *testing.T) {
+	r := newTracklistRelease("Speak To Me", "1:13")
+	r.Title = "The Dark Side Of The Moon (Remastered)"
+	r.Year = 1973
+	r.ActorRoles.Add("Pink Floyd", "performer")
+	queries := planQueries(r)
+	var strategies []string
+	for _, q := range queries {
+		strategies = append(strategies, q.strategy)
+	}
+	assert.Equal(t, []string{StrategyStrict, StrategyRelaxed, StrategyFreeText, StrategyArtistTracks}, strategies)
+	assert.Equal(t, "1973", queries[0].params.Get("year"))
+	assert.Empty(t, queries[1].params.Get("year"))
+	assert.Equal(t, "The Dark Side Of The Moon Pink Floyd", queries[2].params.Get("q"))
+	assert.Equal(t, "Speak To Me", queries[3].params.Get("track"))
+
+	// исполнитель сборников в запросах не используется, совпадающие запросы исключаются
+	r = md.NewRelease()
+	r.Title = "Now 48"
+	r.ActorRoles.Add("Various Artists", "performer")
+	queries = planQueries(r)
+	require.Len(t, queries, 2)
+	assert.Equal(t, StrategyStrict, queries[0].strategy)
+	assert.Empty(t, queries[0].params["artist"])
+	assert.Equal(t, StrategyFreeText, queries[1].strategy)
+}
+
+func TestFakePlannedSearch(t *testing.T) {
+	d, fake := newFakeDiscogs(t)
+
+	// пометка издания в наименовании: строгий и ослабленный запросы не дают результатов
+	r := md.NewRelease()
+	r.Title = "The Dark Side Of The Moon (Remastered)"
+	r.Year = 1973
+	r.ActorRoles.Add("Pink Floyd", "performer")
+	resp := runFakeCmd(t, d, &AudioOnlineRequest{Cmd: "release", Release: r})
+	require.NotEmpty(t, resp.SuggestionSet.Suggestions)
+	assert.Equal(t, "4139588", resp.SuggestionSet.Suggestions[0].Release.IDs[md.DiscogsReleaseID])
+	assert.Equal(t, []string{
+		"/database/search?artist=Pink+Floyd&title=The+Dark+Side+Of+The+Moon+%28Remastered%29&type=release&year=1973",
+		"/database/search?artist=Pink+Floyd&title=The+Dark+Side+Of+The+Moon+%28Remastered%29&type=release",
+		"/database/search?q=The+Dark+Side+Of+The+Moon+Pink+Floyd&type=release",
+	}, searchRequests(fake.Requests()))
+
+	// неизвестное наименование: кандидаты отбираются по исполнителю и наименованию трека
+	r = newTracklistRelease("The Great Gig In The Sky", "4:44")
+	r.Title = "DSOTM"
+	r.ActorRoles.Add("Pink Floyd", "performer")
+	suggestions, err := d.planSearch(r, &d.search)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "4139588", suggestions[0].Release.IDs[md.DiscogsReleaseID])
+	requests := searchRequests(fake.Requests())
+	assert.Contains(t, requests[len(requests)-1], "track=The+Great+Gig+In+The+Sky")
+}
+
+func searchRequests(requests []string) (ret []string) {
+	for _, uri := range requests {
+		if strings.HasPrefix(uri, "/database/search") {
+			ret = append(ret, uri)
+		}
+	}
+	return
+}
@@ -0,0 +1,126 @@
+package discogs
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Rate limit constants
+const (
+	RateUsedHeaderKey      = "X-Discogs-Ratelimit-Used"
+	RateRemainingHeaderKey = "X-Discogs-Ratelimit-Remaining"
+	// Discogs ограничивает количество запросов в скользящем окне длительностью в минуту.
+	RateWindow = time.Minute
+	// Ограничение частоты запросов для неавторизованного клиента (запросов в минуту).
+	AnonymousRateLimit = 25
+)
+
+// rateLimiter распределяет запросы к Discogs API во времени в соответствии с бюджетом запросов,
+// сообщаемым сервером в заголовках каждого ответа.
+// При исчерпании бюджета или ответе 429 запросы приостанавливаются.
+type rateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	used      int
+	remaining int
+	interval  time.Duration
+	next      time.Time
+	log       *log.Logger
+}
+
+func newRateLimiter(limit int, logger *log.Logger) *rateLimiter {
+	rl := &rateLimiter{log: logger}
+	rl.setLimit(limit)
+	rl.remaining = limit
+	return rl
+}
+
+// Wait блокирует выполнение до момента, когда очередной запрос может быть выполнен,
+// и резервирует для него время.
+func (rl *rateLimiter) Wait() {
+	rl.mu.Lock()
+	now := time.Now()
+	at := rl.next
+	if at.Before(now) {
+		at = now
+	}
+	rl.next = at.Add(rl.interval)
+	rl.mu.Unlock()
+
+	time.Sleep(time.Until(at))
+}
+
+// Update обновляет бюджет запросов по заголовкам ответа Discogs.
+func (rl *rateLimiter) Update(header http.Header) {
+	limit, err := strconv.Atoi(header.Get(RateHeaderKey))
+	if err != nil || limit <= 0 {
+		return
+	}
+	used, _ := strconv.Atoi(header.Get(RateUsedHeaderKey))
+	remaining, err := strconv.Atoi(header.Get(RateRemainingHeaderKey))
+	if err != nil {
+		remaining = limit - used
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if limit != rl.limit {
+		rl.setLimit(limit)
+		rl.log.Info("Polling interval: ", rl.interval)
+	}
+	rl.used, rl.remaining = used, remaining
+	if remaining <= 0 {
+		rl.pause(RateWindow)
+	}
+	rl.log.WithField("limit", limit).
+		WithField("used", used).
+		WithField("remaining", remaining).
+		Debug("Rate limit")
+}
+
+// Pause приостанавливает выполнение запросов на время `d`.
+func (rl *rateLimiter) Pause(d time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.pause(d)
+}
+
+// Budget возвращает текущий бюджет запросов: ограничение, использованное и оставшееся
+// количество запросов в окне.
+func (rl *rateLimiter) Budget() (limit, used, remaining int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.limit, rl.used, rl.remaining
+}
+
+// Interval возвращает текущий минимальный интервал между запросами.
+func (rl *rateLimiter) Interval() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.interval
+}
+
+// Нулевое ограничение означает отсутствие ограничения частоты запросов.
+func (rl *rateLimiter) setLimit(limit int) {
+	rl.limit = limit
+	if limit <= 0 {
+		rl.interval = 0
+		return
+	}
+	rl.interval = RateWindow / time.Duration(limit)
+}
+
+func (rl *rateLimiter) pause(d time.Duration) {
+	if till := time.Now().Add(d); till.After(rl.next) {
+		rl.next = till
+		rl.log.WithField("pause", d).
+			WithField("limit", rl.limit).
+			WithField("used", rl.used).
+			WithField("remaining", rl.remaining).
+			Warn("Rate limit exceeded")
+	}
+}
@@ -0,0 +1,147 @@
+package discogs
+
+import (
+	"strings"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+// roleTarget defines the metadata entity a Discogs credit role is related to.
+type roleTarget uint8
+
+const (
+	recordRole roleTarget = iota
+	compositionRole
+	releaseRole
+)
+
+// roleTargets maps the normalized Discogs role name (see normalizeRole) to the metadata entity.
+// Roles missing in the map are considered to be the record roles (performers, producers,
+// engineers etc.).
+var roleTargets = map[string]roleTarget{
+	// release roles
+	"a&r":                releaseRole,
+	"art direction":      releaseRole,
+	"artwork":            releaseRole,
+	"compiled":           releaseRole,
+	"cover":              releaseRole,
+	"design":             releaseRole,
+	"executive producer": releaseRole,
+	"graphics":           releaseRole,
+	"illustration":       releaseRole,
+	"layout":             releaseRole,
+	"liner notes":        releaseRole,
+	"management":         releaseRole,
+	"painting":           releaseRole,
+	"photography":        releaseRole,
+	"sleeve notes":       releaseRole,
+	"typography":         releaseRole,
+	// composition roles
+	"adapted":      compositionRole,
+	"arranged":     compositionRole,
+	"composed":     compositionRole,
+	"composer":     compositionRole,
+	"libretto":     compositionRole,
+	"lyricist":     compositionRole,
+	"lyrics":       compositionRole,
+	"music":        compositionRole,
+	"orchestrated": compositionRole,
+	"songwriter":   compositionRole,
+	"translated":   compositionRole,
+	"words":        compositionRole,
+	"written":      compositionRole,
+}
+
+// credit describes a single Discogs credit role with optional bracketed qualifiers,
+// e.g. "Vocals [Backing]".
+type credit struct {
+	Role       string
+	Qualifiers []string
+}
+
+// parseRoles splits the Discogs role string like "Written-By, Arranged By, Vocals [Lead, Backing]"
+// into the separate credits. Commas inside the brackets do not split the roles.
+func parseRoles(roles string) []credit {
+	var credits []credit
+	var depth, start int
+	for i, ch := range roles {
+		switch ch {
+		case '[':
+			depth++
+		case ']':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				credits = appendCredit(credits, roles[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return appendCredit(credits, roles[start:])
+}
+
+func appendCredit(credits []credit, s string) []credit {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return credits
+	}
+	var c credit
+	if i := strings.Index(s, "["); i >= 0 {
+		c.Role = strings.TrimSpace(s[:i])
+		qualifiers := strings.TrimSuffix(s[i+1:], "]")
+		for _, q := range strings.Split(qualifiers, ",") {
+			if q = strings.TrimSpace(q); q != "" {
+				c.Qualifiers = append(c.Qualifiers, q)
+			}
+		}
+	} else {
+		c.Role = s
+	}
+	if c.Role == "" {
+		return credits
+	}
+	return append(credits, c)
+}
+
+// String returns the credit in the Discogs notation.
+func (c *credit) String() string {
+	if len(c.Qualifiers) == 0 {
+		return c.Role
+	}
+	return c.Role + " [" + strings.Join(c.Qualifiers, ", ") + "]"
+}
+
+// Target defines the metadata entity for the credit.
+func (c *credit) Target() roleTarget {
+	return roleTargets[normalizeRole(c.Role)]
+}
+
+// normalizeRole lowercases the role name and removes the "By" suffix: "Written-By" -> "written".
+func normalizeRole(role string) string {
+	role = strings.ToLower(strings.TrimSpace(role))
+	role = strings.TrimSuffix(role, "-by")
+	role = strings.TrimSuffix(role, " by")
+	return role
+}
+
+// ActorsByRole определяет коллекцию для размещения описания по наименованию роли.
+// Роли уровня релиза (оформление, составление и т.д.) размещаются в сведениях трека.
+func ActorsByRole(track *md.Track, role string) *md.ActorRoles {
+	c := credit{Role: role}
+	if credits := parseRoles(role); len(credits) > 0 {
+		c = credits[0]
+	}
+	return creditActors(track, c)
+}
+
+func creditActors(track *md.Track, c credit) *md.ActorRoles {
+	switch c.Target() {
+	case releaseRole:
+		return &track.ActorRoles
+	case compositionRole:
+		return &track.Composition.ActorRoles
+	}
+	return &track.Record.ActorRoles
+}
@@ -0,0 +1,52 @@
+package discogs
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+func TestParseRoles(t *testing.T) {
+	credits := parseRoles("Bass Guitar, Vocals [Lead, Backing], Written-By")
+	require.Len(t, credits, 3)
+	assert.Equal(t, "Bass Guitar", credits[0].Role)
+	assert.Equal(t, []string{"Lead", "Backing"}, credits[1].Qualifiers)
+	assert.Equal(t, "Vocals [Lead, Backing]", credits[1].String())
+	assert.Equal(t, compositionRole, credits[2].Target())
+
+	assert.Equal(t, releaseRole, parseRoles("Photography By")[0].Target())
+	assert.Equal(t, recordRole, parseRoles("Mixed By [Supervised]")[0].Target())
+	assert.Empty(t, parseRoles(" , "))
+}
+
+func TestReleaseCredits(t *testing.T) {
+	data, err := os.ReadFile("testdata/release.json")
+	require.NoError(t, err)
+	var ri releaseInfo
+	require.NoError(t, json.Unmarshal(data, &ri))
+
+	ri.Artists = append(ri.Artists, artist{Name: "Various", Role: "Compiled By", ID: 194})
+	r := md.NewRelease()
+	ri.Release(r)
+
+	assert.Contains(t, r.ActorRoles["Various"], "Compiled By")
+	assert.Contains(t, r.ActorRoles["George Hardie"], "Artwork [Sleeve Art, Stickers Art]")
+	assert.Equal(t, "1826981", r.Actors["George Hardie"][md.DiscogsArtistID])
+
+	time := r.TrackByPosition("A4")
+	require.NotNil(t, time)
+	assert.Contains(t, time.Composition.ActorRoles["David Gilmour"], "Written-By")
+	assert.Contains(t, time.Record.ActorRoles["Doris Troy"], "Backing Vocals")
+	assert.Contains(t, time.Record.ActorRoles["Roger Waters"], "Synthesizer [Vcs3]")
+	assert.Contains(t, time.Composition.ActorRoles["Roger Waters"], "Lyrics By")
+	assert.Equal(t, "45467", time.Actors["Pink Floyd"][md.DiscogsArtistID])
+
+	run := r.TrackByPosition("A3")
+	assert.Contains(t, run.Composition.ActorRoles["David Gilmour"], "Written-By")
+	assert.NotContains(t, r.TrackByPosition("A5").Composition.ActorRoles, "David Gilmour")
+}
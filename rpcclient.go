@@ -0,0 +1,358 @@
+package discogs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/streadway/amqp"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+// DefaultRequestTimeout - время ожидания ответа микросервиса для запросов, контекст
+// которых не ограничен по времени.
+const DefaultRequestTimeout = time.Minute
+
+// Количество промежуточных ответов, ожидающих обработки. Промежуточные ответы сверх
+// этого количества отбрасываются, окончательный ответ доставляется всегда.
+const streamBuffer = 64
+
+// ErrClientClosed возвращается запросами закрытого клиента или клиента, соединение
+// которого с брокером сообщений было разорвано.
+var ErrClientClosed = errors.New("discogs client is closed")
+
+// Client - клиент микросервиса, работающий через брокер сообщений RabbitMQ.
+// Ответы сопоставляются с запросами по CorrelationId, поэтому клиент может одновременно
+// использоваться несколькими горутинами. Ожидание ответа ограничивается контекстом запроса;
+// ошибки микросервиса возвращаются значениями типа *ServiceError.
+type Client struct {
+	conn    *amqp.Connection
+	ch      *amqp.Channel
+	publish func(amqp.Publishing) error
+	timeout time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*pendingCall
+	closed  bool
+}
+
+// pendingCall - запрос, ожидающий ответа микросервиса. Канал progress создается только
+// для запросов с потоковой выдачей ответа.
+type pendingCall struct {
+	reply    chan []byte
+	progress chan []byte
+}
+
+// ClientOption задает необязательный параметр клиента микросервиса.
+type ClientOption func(*Client)
+
+// WithRequestTimeout задает время ожидания ответа для запросов, контекст которых не
+// ограничен по времени (по умолчанию DefaultRequestTimeout, 0 - без ограничения).
+func WithRequestTimeout(timeout time.Duration) ClientOption {
+	return func(cl *Client) {
+		cl.timeout = timeout
+	}
+}
+
+// NewClient подключается к брокеру сообщений `connstr` и создает клиента микросервиса.
+func NewClient(connstr string, opts ...ClientOption) (*Client, error) {
+	conn, err := amqp.Dial(connstr)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	q, err := ch.QueueDeclare(
+		"",    // name
+		false, // durable
+		false, // delete when unused
+		true,  // exclusive
+		false, // noWait
+		nil,   // arguments
+	)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	msgs, err := ch.Consume(
+		q.Name, // queue
+		"",     // consumer
+		true,   // auto-ack
+		false,  // exclusive
+		false,  // no-local
+		false,  // no-wait
+		nil,    // args
+	)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	cl := newClient(func(msg amqp.Publishing) error {
+		msg.ReplyTo = q.Name
+		return ch.Publish("", ServiceName, false, false, msg)
+	}, opts...)
+	cl.conn, cl.ch = conn, ch
+	go cl.dispatch(msgs)
+	return cl, nil
+}
+
+func newClient(publish func(amqp.Publishing) error, opts ...ClientOption) *Client {
+	cl := &Client{
+		publish: publish,
+		timeout: DefaultRequestTimeout,
+		pending: map[string]*pendingCall{},
+	}
+	for _, opt := range opts {
+		opt(cl)
+	}
+	return cl
+}
+
+// Close закрывает соединение с брокером сообщений. Ожидающие ответа запросы завершаются
+// ошибкой ErrClientClosed.
+func (cl *Client) Close() error {
+	cl.shutdown()
+	if cl.conn == nil {
+		return nil
+	}
+	cl.ch.Close()
+	return cl.conn.Close()
+}
+
+// Распределение ответов микросервиса по ожидающим их запросам. Ответы на отмененные
+// запросы отбрасываются.
+func (cl *Client) dispatch(msgs <-chan amqp.Delivery) {
+	for delivery := range msgs {
+		cl.deliver(delivery.CorrelationId, delivery.Body)
+	}
+	cl.shutdown()
+}
+
+func (cl *Client) deliver(correlationID string, body []byte) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	call, ok := cl.pending[correlationID]
+	if !ok {
+		return
+	}
+	if call.progress != nil {
+		var msg struct {
+			Stage Stage `json:"stage"`
+		}
+		// ошибки разбора ответа обрабатываются как ошибки окончательного ответа
+		if json.Unmarshal(body, &msg) == nil && msg.Stage.Intermediate() {
+			select {
+			case call.progress <- body:
+			default:
+			}
+			return
+		}
+	}
+	delete(cl.pending, correlationID)
+	call.reply <- body
+}
+
+func (cl *Client) shutdown() {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.closed = true
+	for correlationID, call := range cl.pending {
+		delete(cl.pending, correlationID)
+		close(call.reply)
+	}
+}
+
+// Do отправляет запрос микросервису и ожидает ответа в пределах контекста `ctx`.
+// Ошибка, содержащаяся в ответе микросервиса, возвращается вместе с ответом.
+func (cl *Client) Do(ctx context.Context, req *AudioOnlineRequest) (*AudioOnlineResponse, error) {
+	return cl.do(ctx, req, nil)
+}
+
+// DoStream отправляет запрос с потоковой выдачей ответа: промежуточные ответы передаются
+// `progress` в порядке получения до возврата окончательного ответа.
+func (cl *Client) DoStream(
+	ctx context.Context,
+	req *AudioOnlineRequest,
+	progress func(*AudioOnlineResponse)) (*AudioOnlineResponse, error) {
+	streamReq := *req
+	streamReq.Stream = true
+	return cl.do(ctx, &streamReq, progress)
+}
+
+func (cl *Client) do(
+	ctx context.Context,
+	req *AudioOnlineRequest,
+	progress func(*AudioOnlineResponse)) (*AudioOnlineResponse, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := ctx.Deadline(); !ok && cl.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cl.timeout)
+		defer cancel()
+	}
+
+	id, _ := uuid.NewV4()
+	correlationID := id.String()
+	call := &pendingCall{reply: make(chan []byte, 1)}
+	if progress != nil {
+		call.progress = make(chan []byte, streamBuffer)
+	}
+	cl.mu.Lock()
+	if cl.closed {
+		cl.mu.Unlock()
+		return nil, ErrClientClosed
+	}
+	cl.pending[correlationID] = call
+	cl.mu.Unlock()
+	defer func() {
+		cl.mu.Lock()
+		delete(cl.pending, correlationID)
+		cl.mu.Unlock()
+	}()
+
+	// запрос с истекшим сроком ожидания не отправляется
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	msg := amqp.Publishing{
+		ContentType:   "application/json",
+		CorrelationId: correlationID,
+		Body:          data,
+	}
+	// запрос, не обработанный до истечения срока ожидания, удаляется из очереди брокером
+	if deadline, ok := ctx.Deadline(); ok {
+		expiration := int64(time.Until(deadline)/time.Millisecond) + 1
+		if expiration < 1 {
+			expiration = 1
+		}
+		msg.Expiration = strconv.FormatInt(expiration, 10)
+	}
+	if err = cl.publish(msg); err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case body := <-call.progress:
+			notify(progress, body)
+		case body, ok := <-call.reply:
+			if !ok {
+				return nil, ErrClientClosed
+			}
+			// промежуточные ответы, полученные до окончательного
+			for len(call.progress) > 0 {
+				notify(progress, <-call.progress)
+			}
+			return parseAnswer(body)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func notify(progress func(*AudioOnlineResponse), body []byte) {
+	resp := &AudioOnlineResponse{}
+	if json.Unmarshal(body, resp) == nil {
+		progress(resp)
+	}
+}
+
+// Разбор ответа микросервиса. Пустой ответ соответствует команде "ping".
+func parseAnswer(body []byte) (*AudioOnlineResponse, error) {
+	resp := &AudioOnlineResponse{}
+	if len(body) == 0 {
+		return resp, nil
+	}
+	if err := json.Unmarshal(body, resp); err != nil {
+		return nil, err
+	}
+	return resp, resp.Err()
+}
+
+// Release ищет метаданные релиза по ID в БД Discogs, штрих-коду, номеру в каталоге или
+// неполным данным.
+func (cl *Client) Release(ctx context.Context, r *md.Release) (*md.SuggestionSet, error) {
+	resp, err := cl.Do(ctx, &AudioOnlineRequest{Cmd: "release", Release: r})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Unwrap()
+}
+
+// ReleaseStream ищет метаданные релиза, как и Release, передавая `progress` промежуточные
+// результаты поиска по неполным данным (см. Stage).
+func (cl *Client) ReleaseStream(
+	ctx context.Context, r *md.Release, progress func(*AudioOnlineResponse)) (*md.SuggestionSet, error) {
+	resp, err := cl.DoStream(ctx, &AudioOnlineRequest{Cmd: "release", Release: r}, progress)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Unwrap()
+}
+
+// ReleaseByID запрашивает релиз по его ID в БД Discogs.
+func (cl *Client) ReleaseByID(ctx context.Context, id string) (*md.SuggestionSet, error) {
+	r := md.NewRelease()
+	r.IDs[md.DiscogsReleaseID] = id
+	return cl.Release(ctx, r)
+}
+
+// Artist запрашивает сведения об исполнителе по ID в БД Discogs или имени.
+func (cl *Client) Artist(ctx context.Context, actor *Actor) ([]*ArtistSuggestion, error) {
+	resp, err := cl.Do(ctx, &AudioOnlineRequest{Cmd: "artist", Actor: actor})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Artists, nil
+}
+
+// Label запрашивает сведения о лейбле по ID в БД Discogs или наименованию и, если указан
+// `pagination`, страницу списка его релизов.
+func (cl *Client) Label(ctx context.Context, lbl *md.Label, pagination *Pagination) ([]*LabelSuggestion, error) {
+	resp, err := cl.Do(ctx, &AudioOnlineRequest{Cmd: "label", Label: lbl, Pagination: pagination})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Labels, nil
+}
+
+// Master запрашивает мастер-релиз по ID в БД Discogs и список его версий.
+func (cl *Client) Master(
+	ctx context.Context, id string, filter *VersionFilter, pagination *Pagination) (*MasterProfile, error) {
+	r := md.NewRelease()
+	r.IDs[md.DiscogsMasterID] = id
+	resp, err := cl.Do(ctx, &AudioOnlineRequest{
+		Cmd:        "master",
+		Release:    r,
+		Versions:   filter,
+		Pagination: pagination})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Master, nil
+}
+
+// Info запрашивает сведения о версии микросервиса.
+func (cl *Client) Info(ctx context.Context) (*ServiceInfo, error) {
+	resp, err := cl.Do(ctx, &AudioOnlineRequest{Cmd: "info"})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Info, nil
+}
+
+// Ping проверяет доступность микросервиса.
+func (cl *Client) Ping(ctx context.Context) error {
+	_, err := cl.Do(ctx, &AudioOnlineRequest{Cmd: "ping"})
+	return err
+}
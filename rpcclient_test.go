@@ -0,0 +1,110 @@
+package discogs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+// Клиент, запросы которого обрабатываются сервисом `d` без брокера сообщений.
+func newLoopbackClient(t *testing.T, d *Discogs) *Client {
+	var cl *Client
+	cl = newClient(func(msg amqp.Publishing) error {
+		go func() {
+			req := NewAudioOnlineRequest()
+			if !assert.NoError(t, json.Unmarshal(msg.Body, req)) {
+				return
+			}
+			data, err := d.Execute(req)
+			if errors.Is(err, ErrUnknownCommand) {
+				data = errorAnswer(NewErrorResponse(err, "Message dispatcher"))
+			} else if err != nil {
+				data = errorAnswer(NewErrorResponse(err, req.Cmd))
+			}
+			cl.deliver(msg.CorrelationId, data)
+		}()
+		return nil
+	})
+	return cl
+}
+
+func TestClient(t *testing.T) {
+	d, _ := newFakeDiscogs(t)
+	cl := newLoopbackClient(t, d)
+	ctx := context.Background()
+
+	require.NoError(t, cl.Ping(ctx))
+
+	set, err := cl.ReleaseByID(ctx, "4139588")
+	require.NoError(t, err)
+	require.Len(t, set.Suggestions, 1)
+	assert.Equal(t, "The Dark Side Of The Moon", set.Suggestions[0].Release.Title)
+
+	_, err = cl.ReleaseByID(ctx, "1")
+	var serviceErr *ServiceError
+	require.True(t, errors.As(err, &serviceErr))
+	assert.Equal(t, "release", serviceErr.Context)
+	assert.Equal(t, CodeNotFound, serviceErr.Code)
+	assert.False(t, serviceErr.Temporary())
+
+	master, err := cl.Master(ctx, "10362", &VersionFilter{Country: "UK", Year: 1973}, nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, master.Versions)
+
+	_, err = cl.Do(ctx, &AudioOnlineRequest{Cmd: "x"})
+	require.True(t, errors.As(err, &serviceErr))
+	assert.Equal(t, "unknown command: x", serviceErr.Message)
+	assert.Equal(t, "Message dispatcher", serviceErr.Context)
+	assert.Equal(t, CodeInvalidRequest, serviceErr.Code)
+
+	_, err = cl.Artist(ctx, &Actor{})
+	require.True(t, errors.As(err, &serviceErr))
+	assert.Equal(t, CodeInvalidRequest, serviceErr.Code)
+}
+
+func TestClientCancellation(t *testing.T) {
+	published := make(chan amqp.Publishing, 2)
+	cl := newClient(func(msg amqp.Publishing) error {
+		published <- msg
+		return nil
+	}, WithRequestTimeout(20*time.Millisecond))
+
+	_, err := cl.ReleaseByID(context.Background(), "1")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.NotEmpty(t, (<-published).Expiration)
+	assert.Empty(t, cl.pending)
+	cl.deliver("late", []byte("{}")) // ответ на отмененный запрос отбрасывается
+
+	done := make(chan error)
+	go func() {
+		_, err := cl.Release(context.Background(), md.NewRelease())
+		done <- err
+	}()
+	<-published
+	require.NoError(t, cl.Close())
+	assert.Equal(t, ErrClientClosed, <-done)
+	assert.Equal(t, ErrClientClosed, cl.Ping(context.Background()))
+}
+
+func TestClientExpiredContext(t *testing.T) {
+	published := make(chan amqp.Publishing, 1)
+	cl := newClient(func(msg amqp.Publishing) error {
+		published <- msg
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	_, err := cl.ReleaseByID(ctx, "1")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Empty(t, published)
+	assert.Empty(t, cl.pending)
+}
@@ -2,17 +2,25 @@ package discogs
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
 
 	md "github.com/ytsiuryn/ds-audiomd"
 	srv "github.com/ytsiuryn/ds-microservice"
+	tp "github.com/ytsiuryn/go-stringutils"
 )
 
 // Константы микросервиса.
@@ -25,7 +33,10 @@ const (
 	MinSearchShortResult = .5
 	MinSearchFullResult  = .75
 	MaxPreSuggestions    = 7
+	MinPreSuggestions    = 1
 	MaxSuggestions       = 3
+	// Максимальное количество просматриваемых страниц результатов поиска (см. SearchOptions).
+	MaxSearchPages = 5
 )
 
 // Client constants
@@ -36,106 +47,293 @@ const (
 
 	BaseURL       = "https://api.discogs.com/"
 	RateHeaderKey = "X-Discogs-Ratelimit"
+	MaxPageSize   = 100
 )
 
+// Модули, версии которых сообщает команда "info".
+var infoModules = []string{
+	"github.com/ytsiuryn/ds-audiomd",
+	"github.com/ytsiuryn/ds-microservice",
+}
+
 // Discogs описывает внутреннее состояние клиента Discogs.
 type Discogs struct {
 	*srv.Service
-	headers map[string]string
-	poller  *srv.WebPoller
+	api     *apiClient
+	web     *httpBackend
+	backend Backend
+	search  SearchOptions
+	// обработка запросов RabbitMQ
+	conn    *amqp.Connection
+	ch      *amqp.Channel
+	publish func(key string, msg amqp.Publishing) error
+	workers int
+	sched   *scheduler
+	wg      sync.WaitGroup
+}
+
+// Option задает необязательный параметр клиента Discogs.
+type Option func(*Discogs)
+
+// WithCache подключает кэш ответов Discogs API, например:
+//
+//	mem := discogs.NewMemoryCache(1000, 64<<20)
+//	disk, _ := discogs.NewDiskCache(dir, 1<<30)
+//	cl := discogs.New(app, token, discogs.WithCache(discogs.NewLayeredCache(mem, disk)))
+func WithCache(cache Cache) Option {
+	return func(d *Discogs) {
+		d.api.cache = cache
+	}
+}
+
+// WithDump переключает клиент на локальное хранилище дампов Discogs (автономный режим).
+// Запросы обслуживаются хранилищем без обращения к сети и без ограничения частоты запросов.
+func WithDump(store *DumpStore) Option {
+	return WithBackend(store)
+}
+
+// WithBackend заменяет источник данных Discogs API альтернативным (например, подделкой
+// в тестах или локальным набором данных).
+func WithBackend(backend Backend) Option {
+	return func(d *Discogs) {
+		d.backend = backend
+	}
+}
+
+// WithBaseURL задает базовый URL Discogs API (по умолчанию BaseURL).
+func WithBaseURL(baseURL string) Option {
+	return func(d *Discogs) {
+		d.web.baseURL = normalizeBaseURL(baseURL)
+	}
+}
+
+// WithHTTPClient задает HTTP клиент для запросов к Discogs API.
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Discogs) {
+		d.api.client = client
+	}
+}
+
+// WithWorkers задает количество параллельных обработчиков запросов RabbitMQ
+// (по умолчанию DefaultWorkers). Служебные команды и ответы из кэша дополнительно
+// обрабатываются отдельным обработчиком.
+func WithWorkers(n int) Option {
+	return func(d *Discogs) {
+		if n > 0 {
+			d.workers = n
+		}
+	}
+}
+
+// WithSearchOptions задает параметры поиска релиза для запросов, в которых они не указаны.
+// Незаданные поля `opts` сохраняют значения по умолчанию.
+func WithSearchOptions(opts SearchOptions) Option {
+	return func(d *Discogs) {
+		d.search = d.search.merge(&opts)
+	}
+}
+
+// Параметры поиска релиза по умолчанию.
+func defaultSearchOptions() SearchOptions {
+	minShortResult, minFullResult := MinSearchShortResult, MinSearchFullResult
+	minCandidates := MinPreSuggestions
+	fetchMasters := true
+	return SearchOptions{
+		MinShortResult: &minShortResult,
+		MinFullResult:  &minFullResult,
+		MaxCandidates:  MaxPreSuggestions,
+		MinCandidates:  &minCandidates,
+		MaxSuggestions: MaxSuggestions,
+		SearchPages:    1,
+		FetchMasters:   &fetchMasters,
+	}
+}
+
+// Параметры поиска, заданные полями `o` (если указаны) поверх `opts`. Пороговые значения
+// и флаги задаются указателями, т.к. их нулевые значения допустимы; нулевые значения
+// остальных полей означают, что поле не задано.
+func (opts SearchOptions) merge(o *SearchOptions) SearchOptions {
+	if o == nil {
+		return opts
+	}
+	if o.MinShortResult != nil {
+		opts.MinShortResult = o.MinShortResult
+	}
+	if o.MinFullResult != nil {
+		opts.MinFullResult = o.MinFullResult
+	}
+	if o.MaxCandidates != 0 {
+		opts.MaxCandidates = o.MaxCandidates
+	}
+	if o.MinCandidates != nil {
+		opts.MinCandidates = o.MinCandidates
+	}
+	if o.MaxSuggestions != 0 {
+		opts.MaxSuggestions = o.MaxSuggestions
+	}
+	if o.SearchPages != 0 {
+		opts.SearchPages = o.SearchPages
+	}
+	if o.FetchMasters != nil {
+		opts.FetchMasters = o.FetchMasters
+	}
+	return opts
+}
+
+func (opts *SearchOptions) validate() error {
+	switch {
+	case opts.minShortResult() < 0 || opts.minShortResult() > 1:
+		return fmt.Errorf("%w: min_short_result must be within [0, 1]", ErrInvalidRequest)
+	case opts.minFullResult() < 0 || opts.minFullResult() > 1:
+		return fmt.Errorf("%w: min_full_result must be within [0, 1]", ErrInvalidRequest)
+	case opts.MaxCandidates < 1 || opts.MaxCandidates > MaxPageSize:
+		return fmt.Errorf("%w: max_candidates must be within [1, %d]", ErrInvalidRequest, MaxPageSize)
+	case opts.minCandidates() < 0 || opts.minCandidates() > opts.MaxCandidates:
+		return fmt.Errorf("%w: min_candidates must be within [0, max_candidates]", ErrInvalidRequest)
+	case opts.MaxSuggestions < 1:
+		return fmt.Errorf("%w: max_suggestions must be positive", ErrInvalidRequest)
+	case opts.SearchPages < 1 || opts.SearchPages > MaxSearchPages:
+		return fmt.Errorf("%w: search_pages must be within [1, %d]", ErrInvalidRequest, MaxSearchPages)
+	}
+	return nil
+}
+
+func (opts *SearchOptions) minShortResult() float64 {
+	if opts.MinShortResult == nil {
+		return MinSearchShortResult
+	}
+	return *opts.MinShortResult
+}
+
+func (opts *SearchOptions) minFullResult() float64 {
+	if opts.MinFullResult == nil {
+		return MinSearchFullResult
+	}
+	return *opts.MinFullResult
+}
+
+func (opts *SearchOptions) minCandidates() int {
+	if opts.MinCandidates == nil {
+		return MinPreSuggestions
+	}
+	return *opts.MinCandidates
+}
+
+func (opts *SearchOptions) fetchMasters() bool {
+	return opts.FetchMasters == nil || *opts.FetchMasters
 }
 
 // New создает объект нового клиента Discogs.
-func New(app, token string) *Discogs {
+func New(app, token string, opts ...Option) *Discogs {
 	ret := &Discogs{
 		Service: srv.NewService(ServiceName),
-		headers: map[string]string{
+		search:  defaultSearchOptions(),
+		workers: DefaultWorkers,
+		sched:   newScheduler(),
+	}
+	ret.api = newAPIClient(
+		map[string]string{
 			"User-Agent":    app,
 			"Authorization": "Discogs token=" + token,
 		},
-		poller: srv.NewWebPoller(time.Second)}
-	ret.poller.Log = ret.Log
+		ret.Log)
+	ret.web = &httpBackend{api: ret.api, baseURL: BaseURL}
+	ret.backend = ret.web
+	for _, opt := range opts {
+		opt(ret)
+	}
 	return ret
 }
 
 // AnswerWithError заполняет структуру ответа информацией об ошибке.
 func (d *Discogs) AnswerWithError(delivery *amqp.Delivery, err error, context string) {
 	d.LogOnErrorWithContext(err, context)
-	req := &AudioOnlineResponse{
-		Error: &srv.ErrorResponse{
-			Error:   err.Error(),
-			Context: context,
-		},
-	}
-	data, err := json.Marshal(req)
+	d.Answer(delivery, errorAnswer(NewErrorResponse(err, context)))
+}
+
+// Данные ответа микросервиса с информацией об ошибке.
+func errorAnswer(errResp *ErrorResponse) []byte {
+	data, err := json.Marshal(&AudioOnlineResponse{Error: errResp})
 	srv.FailOnError(err, "Answer marshalling error")
-	d.Answer(delivery, data)
+	return data
 }
 
 // TestPollingInterval выполняет определение частоты опроса сервера на примере тестового запроса.
-// Периодичность расчитывается в наносекундах.
+// В дальнейшем частота опроса уточняется по заголовкам каждого ответа сервера.
 func (d *Discogs) TestPollingInterval() {
-	resource := d.poller.Head(BaseURL, d.headers)
-	if resource.Err != nil {
-		srv.FailOnError(resource.Err, "Polling interval testing")
+	if d.backend != d.web {
+		d.Log.Infof("Offline mode: %T backend is used", d.backend)
+		return
+	}
+	header, err := d.api.Head(d.web.baseURL)
+	if err != nil {
+		srv.FailOnError(err, "Polling interval testing")
 	}
 
-	if len(resource.Response.Header) == 0 {
+	if header.Get(RateHeaderKey) == "" {
 		d.Log.Warn(`Define DISCOGS_APP/DISCOGS_PERSONAL_TOKEN environment variables
 	to achieve the maximum number of requests per minute`)
-		d.poller.SetPollingInterval(time.Duration(2400) * time.Millisecond)
-		return
-	}
-
-	rate, err := strconv.Atoi(string(resource.Response.Header[RateHeaderKey][0]))
-	if err != nil {
-		d.LogOnErrorWithContext(err, "header 'X-Discogs-Ratelimit' conversion")
 		return
 	}
-	pollingInterval := time.Duration(60*1000/rate) * time.Millisecond
 
-	d.poller.SetPollingInterval(pollingInterval)
-	d.Log.Info("Polling interval: ", pollingInterval)
+	limit, used, remaining := d.api.limiter.Budget()
+	d.Log.WithField("limit", limit).
+		WithField("used", used).
+		WithField("remaining", remaining).
+		Info("Polling interval: ", d.api.limiter.Interval())
 }
 
-// StartWithConnection запускает Web Poller и цикл обработки входящих запросов.
+// StartWithConnection запускает цикл обработки входящих запросов.
+// Запросы выполняются параллельно несколькими обработчиками (см. WithWorkers).
 // Контролирует сигнал завершения цикла и последующего освобождения ресурсов микросервиса.
 func (d *Discogs) StartWithConnection(connstr string) {
-	msgs := d.Service.ConnectToMessageBroker(connstr)
+	msgs := d.connect(connstr)
 
-	d.poller.Start()
 	go d.TestPollingInterval()
 
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
-	go func() {
-		for delivery := range msgs {
-			req := NewAudioOnlineRequest()
-			if err := json.Unmarshal(delivery.Body, req); err != nil {
-				d.AnswerWithError(&delivery, err, "Message dispatcher")
-				continue
-			}
-			d.logRequest(req)
-			d.RunCmd(req, &delivery)
-		}
-	}()
+	go d.serve(msgs)
 
-	d.Log.Info("Awaiting RPC requests")
+	d.Log.WithField("workers", d.workers).Info("Awaiting RPC requests")
 	<-c
 
 	d.cleanup()
 }
 
+// Остановка получения запросов и ожидание завершения выполняемых запросов.
 func (d *Discogs) cleanup() {
-	d.Service.Cleanup()
+	d.LogOnError(d.ch.Cancel(d.Name, false))
+	d.sched.close()
+	d.wg.Wait()
+	d.ch.Close()
+	d.conn.Close()
+	d.Log.Infoln("stopped")
 }
 
-// Отображение сведений о выполняемом запросе.
-func (d *Discogs) logRequest(req *AudioOnlineRequest) {
-	if req.Release != nil {
+// Отображение сведений о выполняемом запросе с идентификатором `requestID`.
+func (d *Discogs) logRequest(requestID string, req *AudioOnlineRequest) {
+	entry := d.Log.WithField("request_id", requestID)
+	if req.Cache != nil {
+		entry.WithField("action", req.Cache.Action).Info(req.Cmd + "()")
+	} else if req.Actor != nil {
+		if id, ok := req.Actor.IDs[md.DiscogsArtistID]; ok {
+			entry.WithField("artist", id).Info(req.Cmd + "()")
+		} else {
+			entry.WithField("artist", req.Actor.Name).Info(req.Cmd + "()")
+		}
+	} else if req.Label != nil {
+		if id, ok := req.Label.IDs[md.DiscogsLabelID]; ok {
+			entry.WithField("label", id).Info(req.Cmd + "()")
+		} else {
+			entry.WithField("label", req.Label.Label).Info(req.Cmd + "()")
+		}
+	} else if req.Release != nil {
 		if _, ok := req.Release.IDs[md.DiscogsReleaseID]; ok {
-			d.Log.WithField("release", req.Release.IDs[md.DiscogsReleaseID]).Info(req.Cmd + "()")
+			entry.WithField("release", req.Release.IDs[md.DiscogsReleaseID]).Info(req.Cmd + "()")
+		} else if id := masterID(req.Release); id != "" {
+			entry.WithField("master", id).Info(req.Cmd + "()")
 		} else { // TODO: может стоит офомить метод String() для md.Release?
 			var args []string
 			if actor := req.Release.ActorRoles.Filter(md.IsPerformer).First(); actor != "" {
@@ -147,27 +345,33 @@ func (d *Discogs) logRequest(req *AudioOnlineRequest) {
 			if req.Release.Year != 0 {
 				args = append(args, strconv.Itoa(req.Release.Year))
 			}
-			d.Log.WithField("release", strings.Join(args, "-")).Info(req.Cmd + "()")
+			entry.WithField("release", strings.Join(args, "-")).Info(req.Cmd + "()")
 		}
 	} else {
-		d.Log.Info(req.Cmd + "()")
+		entry.Info(req.Cmd + "()")
 	}
 }
 
 // RunCmd вызывает командам  запроса методы сервиса и возвращает результат клиенту.
+// Если запрос команды "release" требует потоковой выдачи, промежуточные ответы
+// публикуются по мере поиска, а окончательный ответ (в т.ч. с ошибкой) отмечается этапом StageDone.
 func (d *Discogs) RunCmd(req *AudioOnlineRequest, delivery *amqp.Delivery) {
-	var data []byte
-	var err error
-
-	switch req.Cmd {
-	case "release":
-		data, err = d.release(req)
-	default:
-		d.Service.RunCmd(req.Cmd, delivery)
+	var progress progressFunc
+	if req.Stream && req.Cmd == "release" {
+		progress = d.streamTo(delivery)
+	}
+	data, err := d.execute(req, progress)
+	if errors.Is(err, ErrUnknownCommand) {
+		d.AnswerWithError(delivery, err, "Message dispatcher")
 		return
 	}
 
-	if err != nil {
+	if err != nil && progress != nil {
+		d.LogOnErrorWithContext(err, req.Cmd)
+		data, err = json.Marshal(&AudioOnlineResponse{Error: NewErrorResponse(err, req.Cmd), Stage: StageDone})
+		srv.FailOnError(err, "Answer marshalling error")
+		d.Answer(delivery, data)
+	} else if err != nil {
 		d.AnswerWithError(delivery, err, req.Cmd)
 	} else {
 		d.Log.Debug(string(data))
@@ -175,28 +379,94 @@ func (d *Discogs) RunCmd(req *AudioOnlineRequest, delivery *amqp.Delivery) {
 	}
 }
 
-// Обрабатываются следующие сущности: release (actor и label будут добавлены позже).
-func (d *Discogs) release(request *AudioOnlineRequest) ([]byte, error) {
-	var err error
+// Execute выполняет команду запроса независимо от транспорта (RabbitMQ или HTTP) и
+// возвращает JSON представление AudioOnlineResponse. Команда "ping" возвращает пустой ответ.
+func (d *Discogs) Execute(req *AudioOnlineRequest) ([]byte, error) {
+	return d.execute(req, nil)
+}
+
+func (d *Discogs) execute(req *AudioOnlineRequest, progress progressFunc) ([]byte, error) {
+	switch req.Cmd {
+	case "release":
+		return d.release(req, progress)
+	case "artist":
+		return d.artist(req)
+	case "label":
+		return d.label(req)
+	case "master":
+		return d.master(req)
+	case "cache":
+		return d.cache(req)
+	case "info":
+		return d.info()
+	case "ping":
+		return []byte{}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownCommand, req.Cmd)
+}
+
+// Сведения о версии микросервиса и используемом источнике данных.
+func (d *Discogs) info() ([]byte, error) {
+	info := &ServiceInfo{
+		Name:      ServiceName,
+		BuildTime: srv.BuildTime(time.RFC3339),
+		Modules:   srv.Modules(infoModules...),
+		Backend:   fmt.Sprintf("%T", d.backend),
+		Cache:     d.api.cache != nil,
+	}
+	if d.backend == d.web {
+		info.Backend = d.web.baseURL
+	}
+	return json.Marshal(AudioOnlineResponse{Info: info})
+}
+
+// Обрабатываются сведения о релизе по ID в БД Discogs, по штрих-коду или номеру в каталоге
+// лейбла и, если точный поиск не дал результатов, по неполным данным.
+// Промежуточные результаты поиска по неполным данным передаются `progress` (если задан).
+func (d *Discogs) release(request *AudioOnlineRequest, progress progressFunc) ([]byte, error) {
+	if request.Release == nil {
+		return nil, fmt.Errorf("%w: release data is absent", ErrInvalidRequest)
+	}
+	if request.Release.Publishing == nil {
+		request.Release.Publishing = md.NewPublishing()
+	}
+	opts := d.search.merge(request.Search)
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
 	var set *md.SuggestionSet
 
 	if _, ok := request.Release.IDs[md.DiscogsReleaseID]; ok {
-		set, err = d.searchReleaseByID(request.Release.IDs[md.DiscogsReleaseID])
-	} else {
-		set, err = d.searchReleaseByIncompleteData(request.Release)
+		set, err = d.searchReleaseByID(request.Release.IDs[md.DiscogsReleaseID], &opts)
+	} else if set, err = d.searchReleaseByIdentifiers(request.Release, &opts); err == nil &&
+		len(set.Suggestions) == 0 {
+		set, err = d.searchReleaseByIncompleteData(request.Release, &opts, progress)
 	}
 	if err != nil {
 		return nil, err
 	}
 
+	resp := AudioOnlineResponse{SuggestionSet: set}
+	if request.Explain {
+		resp.Explanations = map[string]*Explanation{}
+		for _, s := range set.Suggestions {
+			e := explainScore(request.Release, s.Release)
+			e.Score = s.SourceSimilarity
+			resp.Explanations[s.Release.IDs[md.DiscogsReleaseID]] = e
+		}
+	}
 	set.Optimize()
 
-	return json.Marshal(AudioOnlineResponse{SuggestionSet: set})
+	if progress != nil {
+		resp.Stage = StageDone
+	}
+	return json.Marshal(resp)
 }
 
-func (d *Discogs) searchReleaseByID(id string) (*md.SuggestionSet, error) {
+func (d *Discogs) searchReleaseByID(id string, opts *SearchOptions) (*md.SuggestionSet, error) {
 	r := md.NewRelease()
-	if err := d.releaseByID(id, r); err != nil {
+	if err := d.newFetcher(opts.fetchMasters()).release(id, r); err != nil {
 		return nil, err
 	}
 	set := md.NewSuggestionSet()
@@ -209,42 +479,122 @@ func (d *Discogs) searchReleaseByID(id string) (*md.SuggestionSet, error) {
 	return set, nil
 }
 
-func (d *Discogs) searchReleaseByIncompleteData(release *md.Release) (*md.SuggestionSet, error) {
+// Точный поиск релиза по штрих-коду, а при его отсутствии или неудаче - по номеру в каталоге
+// лейбла. Релизы с совпавшим штрих-кодом считаются полностью соответствующими запросу,
+// совпадение номера в каталоге уточняется сравнением указанных в запросе полей релиза:
+// кандидаты с оценкой не выше opts.MinFullResult исключаются, и при их отсутствии
+// вызывающий переходит к поиску по неполным данным.
+func (d *Discogs) searchReleaseByIdentifiers(
+	release *md.Release, opts *SearchOptions) (*md.SuggestionSet, error) {
+	set := md.NewSuggestionSet()
+	if release.Publishing == nil {
+		return set, nil
+	}
+	var err error
+	barcode := release.Publishing.IDs[md.PublishingBarcode]
+	if digits, ok := barcodeDigits(barcode); ok {
+		gtin, _ := NormalizeBarcode(barcode)
+		set.Suggestions, err = d.exactReleaseSearch(
+			url.Values{"type": {"release"}, "barcode": {digits}},
+			opts,
+			func(result *searchResult) bool { return result.HasBarcode(gtin) },
+			func(r *md.Release) float64 { return 1. },
+			0.)
+		if err != nil || len(set.Suggestions) > 0 {
+			return set, err
+		}
+	}
+	for _, lbl := range release.Publishing.Labels {
+		if lbl.Catno == "" {
+			continue
+		}
+		params := url.Values{"type": {"release"}, "catno": {lbl.Catno}}
+		if lbl.Label != "" {
+			params.Set("label", lbl.Label)
+		}
+		lbl := lbl
+		set.Suggestions, err = d.exactReleaseSearch(
+			params,
+			opts,
+			func(result *searchResult) bool { return result.HasLabel(lbl, opts.minFullResult()) },
+			func(r *md.Release) float64 { return scoreRequestedFields(release, r) },
+			opts.minFullResult())
+		if err != nil || len(set.Suggestions) > 0 {
+			return set, err
+		}
+	}
+	return set, nil
+}
+
+// Результаты поиска, прошедшие проверку `match`, загружаются полностью и оцениваются
+// функцией `score`. Кандидаты с оценкой не выше `minScore` исключаются.
+func (d *Discogs) exactReleaseSearch(
+	params url.Values,
+	opts *SearchOptions,
+	match func(*searchResult) bool,
+	score func(*md.Release) float64,
+	minScore float64) ([]*md.Suggestion, error) {
 	var suggestions []*md.Suggestion
-	// discogs release search...
 	var preResult searchResponse
-	if err := d.poller.DecodeJSON(searchURL(release, "release"), d.headers, &preResult); err != nil {
+	data, err := d.backend.Search(params)
+	if err = decodeDoc(data, err, &preResult); err != nil {
 		return nil, err
 	}
-	var score float64
-	// предварительные предложения
-	for _, r := range preResult.Search() {
-		if score = release.Compare(r); score > MinSearchShortResult {
-			suggestions = append(
-				suggestions,
-				&md.Suggestion{
-					Release:          r,
-					ServiceName:      ServiceName,
-					SourceSimilarity: score,
-				})
+	for i := range preResult.Results {
+		if len(suggestions) == opts.MaxCandidates {
+			break
+		}
+		if !match(&preResult.Results[i]) {
+			continue
 		}
+		r := md.NewRelease()
+		r.IDs[md.DiscogsReleaseID] = strconv.Itoa(int(preResult.Results[i].ID))
+		suggestions = append(suggestions, &md.Suggestion{Release: r, ServiceName: ServiceName})
 	}
-	suggestions = md.BestNResults(suggestions, MaxPreSuggestions)
+	suggestions, err = d.newFetcher(opts.fetchMasters()).candidates(suggestions, func(s *md.Suggestion) bool {
+		s.SourceSimilarity = score(s.Release)
+		return s.SourceSimilarity > minScore
+	})
+	if err != nil {
+		return nil, err
+	}
+	suggestions = md.BestNResults(suggestions, opts.MaxSuggestions)
+	d.Log.WithField("results", len(suggestions)).Debug("Exact search")
+	return suggestions, nil
+}
+
+func (d *Discogs) searchReleaseByIncompleteData(
+	release *md.Release, opts *SearchOptions, progress progressFunc) (*md.SuggestionSet, error) {
+	// предварительные предложения
+	suggestions, err := d.planSearch(release, opts)
+	if err != nil {
+		return nil, err
+	}
+	suggestions = md.BestNResults(suggestions, opts.MaxCandidates)
 	d.Log.WithField("results", len(suggestions)).Debug("Preliminary search")
+	progress.notify(StagePreliminary, suggestions...)
 	// окончательные предложения
-	for i := len(suggestions) - 1; i >= 0; i-- {
-		r := suggestions[i].Release
-		if err := d.releaseByID(r.IDs[md.DiscogsReleaseID], r); err != nil {
-			return nil, err
-		}
-		if score = release.Compare(r); score > MinSearchFullResult {
-			suggestions[i].SourceSimilarity = score
-		} else {
-			suggestions = append(suggestions[:i], suggestions[i+1:]...)
-		}
+	f := d.newFetcher(opts.fetchMasters())
+	if suggestions, err = f.candidates(suggestions, refine(release, opts, progress)); err != nil {
+		return nil, err
 	}
-	suggestions = md.BestNResults(suggestions, MaxSuggestions)
+	if hasPressingData(release) && opts.fetchMasters() {
+		suggestions = d.exploreMasterVersions(f, release, suggestions, opts, progress)
+	}
+	suggestions = md.BestNResults(suggestions, opts.MaxSuggestions)
 	d.Log.WithField("results", len(suggestions)).Debug("Suggestions")
+	if d.Log.IsLevelEnabled(log.DebugLevel) {
+		for _, s := range suggestions {
+			e := explainScore(release, s.Release)
+			entry := d.Log.WithField("release", s.Release.IDs[md.DiscogsReleaseID]).
+				WithField("score", s.SourceSimilarity).
+				WithField("release_score", e.Release)
+			if e.Tracklist != nil {
+				entry = entry.WithField("tracklist_score", *e.Tracklist)
+			}
+			entry.Debug("Suggestion score: " + e.String())
+		}
+	}
 
 	set := md.NewSuggestionSet()
 	set.Suggestions = suggestions
@@ -252,56 +602,506 @@ func (d *Discogs) searchReleaseByIncompleteData(release *md.Release) (*md.Sugges
 	return set, nil
 }
 
+// Исследование версий мастер-релизов лучших кандидатов: версии оцениваются по данным издания
+// (формату, стране, лейблу, номеру в каталоге), лучшие из них загружаются полностью и
+// добавляются к кандидатам. Ошибки загрузки версий не прерывают поиск: уже найденные
+// кандидаты возвращаются в любом случае.
+func (d *Discogs) exploreMasterVersions(
+	f *fetcher,
+	release *md.Release,
+	suggestions []*md.Suggestion,
+	opts *SearchOptions,
+	progress progressFunc) []*md.Suggestion {
+	suggestions = md.BestNResults(suggestions, len(suggestions))
+	known := map[string]bool{}
+	knownMasters := map[string]bool{}
+	var masters []string
+	for _, s := range suggestions {
+		known[s.Release.IDs[md.DiscogsReleaseID]] = true
+		if id := masterID(s.Release); id != "" && !knownMasters[id] {
+			knownMasters[id] = true
+			masters = append(masters, id)
+		}
+	}
+	if len(masters) > MaxExploredMasters {
+		masters = masters[:MaxExploredMasters]
+	}
+	filter := pressingFilter(release)
+	var versions []*md.Suggestion
+	cost := 0
+	for _, id := range masters {
+		if !d.hasBudget(MaxExploredPages + MaxExploredVersions) {
+			d.Log.WithField("master", id).
+				WithField("cost", cost).
+				Info("Master versions exploring is stopped: rate budget is low")
+			break
+		}
+		master := &MasterProfile{}
+		requests, err := d.versionPages(master, id, filter, &Pagination{Page: 1, PerPage: MaxPageSize}, MaxExploredPages)
+		cost += requests
+		if err != nil {
+			d.Log.WithField("master", id).WithError(err).Warn("Master versions are skipped")
+			continue
+		}
+		sort.SliceStable(master.Versions, func(i, j int) bool {
+			return versionCompare(release, master.Versions[i]) >
+				versionCompare(release, master.Versions[j])
+		})
+		explored := 0
+		for _, v := range master.Versions {
+			if explored == MaxExploredVersions || versionCompare(release, v) <= MinVersionResult {
+				break
+			}
+			versionID := v.IDs[md.DiscogsReleaseID]
+			if known[versionID] {
+				continue
+			}
+			known[versionID] = true
+			explored++
+			r := md.NewRelease()
+			r.IDs[md.DiscogsReleaseID] = versionID
+			versions = append(versions, &md.Suggestion{Release: r, ServiceName: ServiceName})
+		}
+		d.Log.WithField("master", id).
+			WithField("versions", explored).
+			WithField("cost", requests).
+			Debug("Master versions explored")
+	}
+	// ошибки загрузки версий уже отражены в журнале
+	versions, _ = f.candidates(versions, refine(release, opts, progress))
+	return append(suggestions, versions...)
+}
+
+// Оценка кандидата по полным данным релиза: кандидаты с оценкой выше opts.MinFullResult
+// передаются `progress` и остаются в числе предложений.
+func refine(release *md.Release, opts *SearchOptions, progress progressFunc) func(*md.Suggestion) bool {
+	return func(s *md.Suggestion) bool {
+		score := scoreRelease(release, s.Release)
+		if score <= opts.minFullResult() {
+			return false
+		}
+		s.SourceSimilarity = score
+		progress.notify(StageSuggestion, s)
+		return true
+	}
+}
+
+// Результаты поиска с первых `pages` страниц и количество выполненных запросов. Ошибка
+// загрузки последующих страниц не прерывает поиск: используются уже полученные результаты.
+func (d *Discogs) searchPages(params url.Values, pages int) (results []*md.Release, requests int, err error) {
+	for page := 1; page <= pages; page++ {
+		if page > 1 {
+			params.Set("page", strconv.Itoa(page))
+		}
+		var preResult searchResponse
+		requests++
+		data, err := d.backend.Search(params)
+		if err = decodeDoc(data, err, &preResult); err != nil {
+			if page == 1 {
+				return nil, requests, err
+			}
+			d.Log.WithField("page", page).WithError(err).Warn("Search page is skipped")
+			break
+		}
+		results = append(results, preResult.Search()...)
+		if page >= preResult.Pagination.Pages {
+			break
+		}
+	}
+	return results, requests, nil
+}
+
 func (d *Discogs) releaseByID(id string, release *md.Release) error {
-	// сведения о релизе...
-	var releaseResp releaseInfo
-	if err := d.poller.DecodeJSON(BaseURL+"releases/"+id, d.headers, &releaseResp); err != nil {
+	return d.newFetcher(true).release(id, release)
+}
+
+// Сведения о мастер-релизе запрашиваются по его ID в БД Discogs вместе со списком версий.
+// Если в запросе указана страница, возвращается только она, иначе - все версии.
+func (d *Discogs) master(request *AudioOnlineRequest) ([]byte, error) {
+	var id string
+	if request.Release != nil {
+		id = masterID(request.Release)
+	}
+	if id == "" {
+		return nil, fmt.Errorf("%w: master ID is absent", ErrInvalidRequest)
+	}
+
+	master, err := d.masterByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.masterVersions(master, id, request.Versions, request.Pagination); err != nil {
+		return nil, err
+	}
+	master.Release.Optimize()
+
+	return json.Marshal(AudioOnlineResponse{Master: master})
+}
+
+func (d *Discogs) masterByID(id string) (*MasterProfile, error) {
+	var masterResp masterInfo
+	data, err := d.backend.Master(id)
+	if err = decodeDoc(data, err, &masterResp); err != nil {
+		return nil, err
+	}
+	master := &MasterProfile{Release: masterResp.Release()}
+	if masterResp.MainRelease != 0 {
+		master.MainReleaseID = strconv.Itoa(int(masterResp.MainRelease))
+	}
+	if masterResp.MostRecentRelease != 0 {
+		master.MostRecentReleaseID = strconv.Itoa(int(masterResp.MostRecentRelease))
+	}
+	return master, nil
+}
+
+// Загрузка версий мастер-релиза. Фильтр передается Discogs и дополнительно проверяется
+// для каждой версии.
+func (d *Discogs) masterVersions(
+	master *MasterProfile, id string, filter *VersionFilter, page *Pagination) error {
+	pages := 1
+	if page == nil {
+		page = &Pagination{Page: 1, PerPage: MaxPageSize}
+		pages = 0
+	}
+	_, err := d.versionPages(master, id, filter, page, pages)
+	return err
+}
+
+// versionPages добавляет к мастер-релизу версии с `pages` страниц списка версий (0 - со всех
+// страниц), начиная со страницы `page`, и возвращает количество выполненных запросов.
+func (d *Discogs) versionPages(
+	master *MasterProfile, id string, filter *VersionFilter, page *Pagination, pages int) (int, error) {
+	requests := 0
+	for {
+		var versionsResp versionsResponse
+		data, err := d.backend.Versions(id, versionsQuery(filter, page))
+		requests++
+		if err = decodeDoc(data, err, &versionsResp); err != nil {
+			return requests, err
+		}
+		for _, vi := range versionsResp.Versions {
+			if v := vi.Version(); filter.Match(v) {
+				master.Versions = append(master.Versions, v)
+			}
+		}
+		master.Pagination = versionsResp.Pagination.Pagination()
+		if requests == pages || versionsResp.Pagination.Page >= versionsResp.Pagination.Pages {
+			break
+		}
+		page.Page++
+	}
+	d.Log.WithField("results", len(master.Versions)).Debug("Master versions")
+	return requests, nil
+}
+
+// ID мастер-релиза может быть указан как в основных, так и в оригинальных сведениях релиза.
+func masterID(r *md.Release) string {
+	if id, ok := r.IDs[md.DiscogsMasterID]; ok {
+		return id
+	}
+	if r.Original != nil {
+		return r.Original.IDs[md.DiscogsMasterID]
+	}
+	return ""
+}
+
+// Параметры запроса страницы списка версий мастер-релиза с учетом фильтра.
+func versionsQuery(filter *VersionFilter, page *Pagination) url.Values {
+	params := pageQuery(page)
+	if filter != nil {
+		if filter.Format != "" {
+			params.Set("format", filter.Format)
+		}
+		if filter.Country != "" {
+			params.Set("country", filter.Country)
+		}
+		if filter.Year != 0 {
+			params.Set("released", strconv.Itoa(filter.Year))
+		}
+	}
+	return params
+}
+
+// Сведения об исполнителе запрашиваются по ID в БД Discogs или по имени.
+func (d *Discogs) artist(request *AudioOnlineRequest) ([]byte, error) {
+	if request.Actor == nil {
+		return nil, fmt.Errorf("%w: actor data is absent", ErrInvalidRequest)
+	}
+	var err error
+	var suggestions []*ArtistSuggestion
+
+	if id, ok := request.Actor.IDs[md.DiscogsArtistID]; ok {
+		suggestions, err = d.searchArtistByID(id)
+	} else {
+		suggestions, err = d.searchArtistByName(request.Actor.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(AudioOnlineResponse{Artists: suggestions})
+}
+
+func (d *Discogs) searchArtistByID(id string) ([]*ArtistSuggestion, error) {
+	artist, err := d.artistByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return []*ArtistSuggestion{
+		{
+			Artist:           artist,
+			ServiceName:      ServiceName,
+			SourceSimilarity: 1.,
+		}}, nil
+}
+
+func (d *Discogs) searchArtistByName(name string) ([]*ArtistSuggestion, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: actor name is empty", ErrInvalidRequest)
+	}
+	var suggestions []*ArtistSuggestion
+	err := d.searchByName("artist", name, func(id string, score float64) error {
+		artist, err := d.artistByID(id)
+		if err != nil {
+			return err
+		}
+		suggestions = append(
+			suggestions,
+			&ArtistSuggestion{
+				Artist:           artist,
+				ServiceName:      ServiceName,
+				SourceSimilarity: score,
+			})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+func (d *Discogs) artistByID(id string) (*ArtistProfile, error) {
+	var artistResp artistInfo
+	data, err := d.backend.Artist(id)
+	if err = decodeDoc(data, err, &artistResp); err != nil {
+		return nil, err
+	}
+	return artistResp.Artist(), nil
+}
+
+// Сведения о лейбле запрашиваются по ID в БД Discogs или по наименованию.
+// При наличии в запросе параметров страницы к лучшему результату добавляется
+// соответствующая страница списка релизов лейбла.
+func (d *Discogs) label(request *AudioOnlineRequest) ([]byte, error) {
+	if request.Label == nil {
+		return nil, fmt.Errorf("%w: label data is absent", ErrInvalidRequest)
+	}
+	var err error
+	var suggestions []*LabelSuggestion
+
+	if id, ok := request.Label.IDs[md.DiscogsLabelID]; ok {
+		suggestions, err = d.searchLabelByID(id)
+	} else {
+		suggestions, err = d.searchLabelByName(request.Label.Label)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Pagination != nil && len(suggestions) > 0 {
+		if err = d.labelReleases(suggestions[0].Label, request.Pagination); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(AudioOnlineResponse{Labels: suggestions})
+}
+
+func (d *Discogs) searchLabelByID(id string) ([]*LabelSuggestion, error) {
+	lbl, err := d.labelByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return []*LabelSuggestion{
+		{
+			Label:            lbl,
+			ServiceName:      ServiceName,
+			SourceSimilarity: 1.,
+		}}, nil
+}
+
+func (d *Discogs) searchLabelByName(name string) ([]*LabelSuggestion, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: label name is empty", ErrInvalidRequest)
+	}
+	var suggestions []*LabelSuggestion
+	err := d.searchByName("label", name, func(id string, score float64) error {
+		lbl, err := d.labelByID(id)
+		if err != nil {
+			return err
+		}
+		suggestions = append(
+			suggestions,
+			&LabelSuggestion{
+				Label:            lbl,
+				ServiceName:      ServiceName,
+				SourceSimilarity: score,
+			})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+// searchByName ищет в БД Discogs сущности типа `kind` ("artist", "label") по наименованию.
+// Для не более чем MaxSuggestions результатов, сходство наименования которых с `name`
+// превышает MinSearchFullResult, в порядке убывания оценки вызывается `link` с ID сущности
+// в БД Discogs и оценкой сходства. Ошибка `link` прерывает обработку результатов.
+func (d *Discogs) searchByName(kind, name string, link func(id string, score float64) error) error {
+	var preResult searchResponse
+	data, err := d.backend.Search(url.Values{"type": {kind}, "q": {name}})
+	if err = decodeDoc(data, err, &preResult); err != nil {
 		return err
 	}
-	releaseResp.Release(release)
-	// сведения о мастер-релизе...
-	if releaseResp.MasterURL != "" {
-		var masterResp masterInfo
-		if err := d.poller.DecodeJSON(releaseResp.MasterURL, d.headers, &masterResp); err != nil {
+	var matches []*searchMatch
+	for _, result := range preResult.Results {
+		if result.Type != "" && result.Type != kind {
+			continue
+		}
+		if score := compareNames(name, result.Title); score > MinSearchFullResult {
+			matches = append(matches, &searchMatch{id: strconv.Itoa(int(result.ID)), score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	if len(matches) > MaxSuggestions {
+		matches = matches[:MaxSuggestions]
+	}
+	d.Log.WithField("type", kind).WithField("results", len(matches)).Debug("Search by name")
+	for _, m := range matches {
+		if err = link(m.id, m.score); err != nil {
 			return err
 		}
-		masterResp.Master(release)
 	}
 	return nil
 }
 
-// All an artist releases
-// /artists/{artist_id}/releases{?sort,sort_order}
-// All a label releases
-// /labels/{label_id}/releases{?page,per_page}
+// searchMatch - ID найденной сущности в БД Discogs и оценка сходства ее наименования.
+type searchMatch struct {
+	id    string
+	score float64
+}
+
+func (d *Discogs) labelByID(id string) (*LabelProfile, error) {
+	var labelResp labelInfo
+	data, err := d.backend.Label(id)
+	if err = decodeDoc(data, err, &labelResp); err != nil {
+		return nil, err
+	}
+	return labelResp.Label(), nil
+}
+
+func (d *Discogs) labelReleases(lbl *LabelProfile, page *Pagination) error {
+	var releasesResp labelReleasesResponse
+	data, err := d.backend.LabelReleases(lbl.IDs[md.DiscogsLabelID], pageQuery(page))
+	if err = decodeDoc(data, err, &releasesResp); err != nil {
+		return err
+	}
+	releasesResp.LabelReleases(lbl)
+	return nil
+}
+
+// Административная команда просмотра и очистки кэша ответов Discogs API.
+func (d *Discogs) cache(request *AudioOnlineRequest) ([]byte, error) {
+	if d.api.cache == nil {
+		return nil, fmt.Errorf("%w: cache is not configured", ErrInvalidRequest)
+	}
+	cacheReq := request.Cache
+	if cacheReq == nil {
+		cacheReq = &CacheRequest{}
+	}
+	info := &CacheInfo{}
+	switch cacheReq.Action {
+	case "", "stats":
+	case "inspect":
+		keys := d.api.cache.Keys()
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !strings.HasPrefix(key, cacheReq.Prefix) {
+				continue
+			}
+			if entry, ok := d.api.cache.Get(key); ok {
+				info.Items = append(info.Items, &CacheItem{
+					Key:          key,
+					Size:         len(entry.Data),
+					ETag:         entry.ETag,
+					LastModified: entry.LastModified,
+					Stored:       entry.Stored,
+					Expires:      entry.Expires,
+				})
+			}
+		}
+	case "purge":
+		info.Purged = PurgeCache(d.api.cache, cacheReq.Prefix)
+		d.Log.WithField("prefix", cacheReq.Prefix).Info("Cache purged: ", info.Purged)
+	default:
+		return nil, fmt.Errorf("%w: unknown cache action %q", ErrInvalidRequest, cacheReq.Action)
+	}
+	info.CacheStats = d.api.cache.Stats()
+	info.CacheCounters = d.api.Counters()
+	return json.Marshal(AudioOnlineResponse{Cache: info})
+}
+
+// Параметры запроса страницы списка Discogs.
+func pageQuery(page *Pagination) url.Values {
+	params := url.Values{}
+	if page.Page > 0 {
+		params.Set("page", strconv.Itoa(page.Page))
+	}
+	if page.PerPage > 0 {
+		params.Set("per_page", strconv.Itoa(page.PerPage))
+	}
+	return params
+}
+
+// Сравнение имен без учета регистра и номера омонима Discogs вида "Name (2)".
+func compareNames(name, other string) float64 {
+	return tp.JaroWinklerDistance(
+		strings.ToLower(trimNameIndex(name)),
+		strings.ToLower(trimNameIndex(other)))
+}
+
+// Discogs различает омонимы номером в скобках в конце имени: "John Smith (2)".
+func trimNameIndex(name string) string {
+	if i := strings.LastIndex(name, " ("); i > 0 && strings.HasSuffix(name, ")") {
+		if _, err := strconv.Atoi(name[i+2 : len(name)-1]); err == nil {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+// Параметры поиска Discogs по неполным данным релиза:
 // GET /database/search?q={query}&{?type,title,release_title,credit,artist,anv,label,genre,style,country,year,format,catno,barcode,track,submitter,contributor}
 // type: release, master, artist, label
-func searchURL(release *md.Release, entityType string) string {
-	builder := strings.Builder{}
-	builder.WriteString(BaseURL)
-	builder.WriteString("database/search?type=")
-	builder.WriteString(entityType)
-	builder.WriteString("&title=")
-	builder.WriteString(release.Title)
-	if performers := release.ActorRoles.Filter(md.IsPerformer); len(performers) > 0 {
-		for actorName := range performers {
-			builder.WriteString("&artist=")
-			builder.WriteString(actorName)
-		}
+func searchParams(release *md.Release, entityType string) url.Values {
+	params := url.Values{"type": {entityType}, "title": {release.Title}}
+	for _, name := range queryPerformers(release) {
+		params.Add("artist", name)
 	}
 	if len(release.Publishing.Labels) > 0 {
-		if len(release.Publishing.Labels[0].Label) > 0 {
-			builder.WriteString("&label=")
-			builder.WriteString(release.Publishing.Labels[0].Label)
+		if lbl := release.Publishing.Labels[0]; lbl.Label != "" {
+			params.Set("label", lbl.Label)
 		}
-		if len(release.Publishing.Labels[0].Catno) > 0 {
-			builder.WriteString("&catno=")
-			builder.WriteString(release.Publishing.Labels[0].Catno)
+		if lbl := release.Publishing.Labels[0]; lbl.Catno != "" {
+			params.Set("catno", lbl.Catno)
 		}
 	}
 	if release.Year != 0 {
-		builder.WriteString("&year=")
-		builder.WriteString(strconv.Itoa(int(release.Year)))
+		params.Set("year", strconv.Itoa(release.Year))
 	}
-	return builder.String()
+	return params
 }
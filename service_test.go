@@ -1,14 +1,24 @@
 package discogs
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
 	md "github.com/ytsiuryn/ds-audiomd"
+	"github.com/ytsiuryn/ds-discogs/discogstest"
 	srv "github.com/ytsiuryn/ds-microservice"
 )
 
@@ -36,10 +46,12 @@ func (suite *DiscogsTestSuite) TestBaseServiceCommands() {
 	correlationID, data, err = srv.CreateCmdRequest("x")
 	require.NoError(suite.T(), err)
 	suite.cl.Request(ServiceName, correlationID, data)
-	vInfo, err := srv.ParseErrorAnswer(suite.cl.Result(correlationID))
+	resp, err := ParseReleaseAnswer(suite.cl.Result(correlationID))
 	require.NoError(suite.T(), err)
-	// {"error": "Unknown command: x", "context": "Message dispatcher"}
-	suite.Equal(vInfo.Error, "Unknown command: x")
+	// {"error": {"error": "unknown command: x", "context": "Message dispatcher", "code": "invalid_request"}}
+	suite.Require().NotNil(resp.Error)
+	suite.Equal("unknown command: x", resp.Error.Error)
+	suite.Equal(CodeInvalidRequest, resp.Error.Code)
 }
 
 func (suite *DiscogsTestSuite) TestSearchRelease() {
@@ -58,7 +70,68 @@ func (suite *DiscogsTestSuite) TestSearchRelease() {
 	require.NoError(suite.T(), err)
 	suite.NotEmpty(resp)
 
-	suite.Equal(resp.Unwrap().Suggestions[0].Release.Title, "The Dark Side Of The Moon")
+	set, err := resp.Unwrap()
+	require.NoError(suite.T(), err)
+	suite.Equal(set.Suggestions[0].Release.Title, "The Dark Side Of The Moon")
+}
+
+func (suite *DiscogsTestSuite) TestSearchArtist() {
+	correlationID, data, err := CreateArtistRequest(&Actor{Name: "Pink Floyd"})
+	require.NoError(suite.T(), err)
+	suite.cl.Request(ServiceName, correlationID, data)
+
+	resp, err := ParseReleaseAnswer(suite.cl.Result(correlationID))
+	require.NoError(suite.T(), err)
+	suite.Nil(resp.Error)
+	suite.NotEmpty(resp.Artists)
+
+	suite.Equal(resp.Artists[0].Artist.IDs[md.DiscogsArtistID], "45467")
+}
+
+func (suite *DiscogsTestSuite) TestSearchLabel() {
+	correlationID, data, err := CreateLabelRequest(md.NewLabel("Harvest", ""), &Pagination{PerPage: 5})
+	require.NoError(suite.T(), err)
+	suite.cl.Request(ServiceName, correlationID, data)
+
+	resp, err := ParseReleaseAnswer(suite.cl.Result(correlationID))
+	require.NoError(suite.T(), err)
+	suite.Nil(resp.Error)
+	suite.NotEmpty(resp.Labels)
+
+	suite.Equal(resp.Labels[0].Label.Name, "Harvest")
+	suite.Len(resp.Labels[0].Label.Releases, 5)
+}
+
+func (suite *DiscogsTestSuite) TestMaster() {
+	correlationID, data, err := CreateMasterRequest(
+		"10362", &VersionFilter{Format: "Vinyl", Country: "UK", Year: 1973}, nil)
+	require.NoError(suite.T(), err)
+	suite.cl.Request(ServiceName, correlationID, data)
+
+	resp, err := ParseReleaseAnswer(suite.cl.Result(correlationID))
+	require.NoError(suite.T(), err)
+	suite.Nil(resp.Error)
+	suite.NotNil(resp.Master)
+
+	suite.Equal(resp.Master.Release.Title, "The Dark Side Of The Moon")
+	suite.NotEmpty(resp.Master.Versions)
+}
+
+func (suite *DiscogsTestSuite) TestClient() {
+	cl, err := NewClient(srv.DefaultRabbitMQConnStr)
+	require.NoError(suite.T(), err)
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), cl.Ping(ctx))
+
+	set, err := cl.ReleaseByID(ctx, "4139588")
+	require.NoError(suite.T(), err)
+	suite.Equal(set.Suggestions[0].Release.Title, "The Dark Side Of The Moon")
+
+	_, err = cl.ReleaseByID(ctx, "0")
+	suite.IsType(&ServiceError{}, err)
 }
 
 func (suite *DiscogsTestSuite) startTestService() {
@@ -72,3 +145,250 @@ func (suite *DiscogsTestSuite) startTestService() {
 func TestDiscogsSuite(t *testing.T) {
 	suite.Run(t, new(DiscogsTestSuite))
 }
+
+// Клиент Discogs, работающий с подделкой Discogs API, обслуживающей фикстуры testdata.
+func newFakeDiscogs(t *testing.T) (*Discogs, *discogstest.Server) {
+	fake := discogstest.NewServer()
+	t.Cleanup(fake.Close)
+	fake.SetRateLimit(6000)
+	for _, fixture := range []struct {
+		name string
+		add  func([]byte) error
+	}{
+		{"release", fake.AddRelease},
+		{"master", fake.AddMaster},
+		{"artist", fake.AddArtist},
+		{"label", fake.AddLabel},
+		{"versions", func(data []byte) error { return fake.AddVersions("10362", data) }},
+		{"label_releases", func(data []byte) error { return fake.AddLabelReleases("2", data) }},
+	} {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", fixture.name+".json"))
+		require.NoError(t, err)
+		require.NoError(t, fixture.add(data))
+	}
+	d := New("", "", WithBaseURL(fake.BaseURL()))
+	d.api.retryDelay = time.Millisecond
+	d.api.limiter.setLimit(0) // бюджет запросов определяется по заголовкам ответов подделки
+	return d, fake
+}
+
+func runFakeCmd(t *testing.T, d *Discogs, req *AudioOnlineRequest) *AudioOnlineResponse {
+	data, err := d.Execute(req)
+	require.NoError(t, err)
+	resp, err := ParseReleaseAnswer(data)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestFakeSearchRelease(t *testing.T) {
+	d, fake := newFakeDiscogs(t)
+
+	r := md.NewRelease()
+	r.Title = "The Dark Side Of The Moon"
+	r.ActorRoles.Add("Pink Floyd", "performer")
+	r.Publishing.Labels = append(r.Publishing.Labels, md.NewLabel("Harvest", "SHVL-804"))
+	resp := runFakeCmd(t, d, &AudioOnlineRequest{Cmd: "release", Release: r})
+	require.NotEmpty(t, resp.SuggestionSet.Suggestions)
+	suggestion := resp.SuggestionSet.Suggestions[0]
+	assert.Equal(t, "4139588", suggestion.Release.IDs[md.DiscogsReleaseID])
+	assert.Greater(t, suggestion.SourceSimilarity, MinSearchFullResult)
+
+	r = md.NewRelease()
+	r.Title = "The Dark Side Of The Moon"
+	r.Year = 1977
+	r.ActorRoles.Add("Pink Floyd", "performer")
+	fake.FailNext(2, http.StatusTooManyRequests, 0)
+	resp = runFakeCmd(t, d, &AudioOnlineRequest{Cmd: "release", Release: r})
+	require.NotEmpty(t, resp.SuggestionSet.Suggestions)
+	assert.Equal(t, "The Dark Side Of The Moon", resp.SuggestionSet.Suggestions[0].Release.Title)
+	assert.Contains(t, fake.Requests(), "/database/search?artist=Pink+Floyd&title=The+Dark+Side+Of+The+Moon&type=release&year=1977")
+}
+
+func TestFakeSearchCatno(t *testing.T) {
+	d, _ := newFakeDiscogs(t)
+
+	// совпадение номера в каталоге и лейбла
+	r := md.NewRelease()
+	r.Publishing.Labels = append(r.Publishing.Labels, md.NewLabel("Harvest", "SHVL-804"))
+	set, err := d.searchReleaseByIdentifiers(r, &d.search)
+	require.NoError(t, err)
+	require.Len(t, set.Suggestions, 1)
+	assert.Equal(t, "4139588", set.Suggestions[0].Release.IDs[md.DiscogsReleaseID])
+
+	// случайное совпадение номера в каталоге не препятствует поиску по неполным данным
+	r = md.NewRelease()
+	r.Title = "Abbey Road"
+	r.ActorRoles.Add("The Beatles", "performer")
+	r.Publishing.Labels = append(r.Publishing.Labels, md.NewLabel("", "SHVL 804"))
+	set, err = d.searchReleaseByIdentifiers(r, &d.search)
+	require.NoError(t, err)
+	assert.Empty(t, set.Suggestions)
+}
+
+func TestFakeExploreVersions(t *testing.T) {
+	d, fake := newFakeDiscogs(t)
+	var versions []map[string]interface{}
+	for i := 0; i < 4*MaxPageSize; i++ {
+		versions = append(versions, map[string]interface{}{
+			"id": 9000000 + i, "label": "Harvest", "country": "UK", "major_formats": []string{"Vinyl"},
+			"format": "LP, Album, RE", "catno": "SHVL 804", "released": "1979"})
+	}
+	data, err := json.Marshal(map[string]interface{}{"versions": versions})
+	require.NoError(t, err)
+	require.NoError(t, fake.AddVersions("10362", data))
+
+	r := md.NewRelease()
+	r.Title = "The Dark Side Of The Moon"
+	r.ActorRoles.Add("Pink Floyd", "performer")
+	r.Country = "UK"
+	r.Disc(1).Format.Media = md.MediaLP
+	explore := func() []string {
+		s := &md.Suggestion{Release: md.NewRelease(), ServiceName: ServiceName, SourceSimilarity: 1.}
+		s.Release.IDs[md.DiscogsReleaseID] = "4139588"
+		s.Release.IDs[md.DiscogsMasterID] = "10362"
+		before := len(fake.Requests())
+		d.exploreMasterVersions(d.newFetcher(true), r, []*md.Suggestion{s}, &d.search, nil)
+		var requests []string
+		for _, uri := range fake.Requests()[before:] {
+			if strings.HasPrefix(uri, "/masters/10362/versions") {
+				requests = append(requests, uri)
+			}
+		}
+		return requests
+	}
+
+	// список версий отбирается по формату и стране и просматривается не далее MaxExploredPages
+	requests := explore()
+	require.Len(t, requests, MaxExploredPages)
+	for _, uri := range requests {
+		assert.Contains(t, uri, "country=UK")
+		assert.Contains(t, uri, "format=Vinyl")
+	}
+
+	// версии не исследуются при недостаточном бюджете запросов
+	fake.SetRateLimit(len(fake.Requests()) + 2)
+	_, err = d.backend.Master("10362")
+	require.NoError(t, err)
+	assert.Empty(t, explore())
+}
+
+func TestFakeEntities(t *testing.T) {
+	d, _ := newFakeDiscogs(t)
+
+	resp := runFakeCmd(t, d, &AudioOnlineRequest{Cmd: "artist", Actor: &Actor{Name: "Pink Floyd"}})
+	require.NotEmpty(t, resp.Artists)
+	assert.Equal(t, "45467", resp.Artists[0].Artist.IDs[md.DiscogsArtistID])
+
+	resp = runFakeCmd(t, d, &AudioOnlineRequest{
+		Cmd: "label", Label: md.NewLabel("Harvest", ""), Pagination: &Pagination{PerPage: 1}})
+	require.NotEmpty(t, resp.Labels)
+	assert.Equal(t, "Harvest", resp.Labels[0].Label.Name)
+	assert.Len(t, resp.Labels[0].Label.Releases, 1)
+	assert.Equal(t, 2, resp.Labels[0].Label.Pagination.Pages)
+
+	r := md.NewRelease()
+	r.IDs[md.DiscogsMasterID] = "10362"
+	resp = runFakeCmd(t, d, &AudioOnlineRequest{
+		Cmd: "master", Release: r, Versions: &VersionFilter{Format: "Vinyl", Country: "UK", Year: 1973}})
+	require.NotNil(t, resp.Master)
+	assert.Equal(t, "The Dark Side Of The Moon", resp.Master.Release.Title)
+	require.Len(t, resp.Master.Versions, 1)
+	assert.Equal(t, "1873013", resp.Master.Versions[0].IDs[md.DiscogsReleaseID])
+}
+
+func TestSearchOptions(t *testing.T) {
+	noMasters := false
+	d := New("", "", WithSearchOptions(SearchOptions{MaxSuggestions: 1, FetchMasters: &noMasters}))
+	minFullResult := .9
+	opts := d.search.merge(&SearchOptions{MinFullResult: &minFullResult, SearchPages: 2})
+	assert.Equal(t, MinSearchShortResult, opts.minShortResult())
+	assert.Equal(t, .9, opts.minFullResult())
+	assert.Equal(t, MaxPreSuggestions, opts.MaxCandidates)
+	assert.Equal(t, MinPreSuggestions, opts.minCandidates())
+	assert.Equal(t, 1, opts.MaxSuggestions)
+	assert.Equal(t, 2, opts.SearchPages)
+	assert.False(t, opts.fetchMasters())
+	assert.NoError(t, opts.validate())
+
+	// нулевые пороговые значения задаются явно
+	zero, noCandidates := 0., 0
+	opts = d.search.merge(&SearchOptions{MinShortResult: &zero, MinFullResult: &zero, MinCandidates: &noCandidates})
+	assert.Zero(t, opts.minShortResult())
+	assert.Zero(t, opts.minFullResult())
+	assert.Zero(t, opts.minCandidates())
+	assert.NoError(t, opts.validate())
+
+	tooHigh, negative, tooMany := 1.5, -.1, MaxPreSuggestions+1
+	for _, o := range []*SearchOptions{
+		{MinShortResult: &tooHigh},
+		{MinFullResult: &negative},
+		{MaxCandidates: -1},
+		{MaxCandidates: MaxPageSize + 1},
+		{MinCandidates: &tooMany},
+		{MaxSuggestions: -1},
+		{SearchPages: MaxSearchPages + 1},
+	} {
+		opts = d.search.merge(o)
+		assert.True(t, errors.Is(opts.validate(), ErrInvalidRequest), "%+v", o)
+	}
+}
+
+func TestFakeSearchPages(t *testing.T) {
+	d, fake := newFakeDiscogs(t)
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "release.json"))
+	require.NoError(t, err)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	for id := 1; id <= discogstest.DefaultPerPage; id++ {
+		doc["id"] = id
+		data, err = json.Marshal(doc)
+		require.NoError(t, err)
+		require.NoError(t, fake.AddRelease(data))
+	}
+
+	r := md.NewRelease()
+	r.Title = "The Dark Side Of The Moon"
+	r.ActorRoles.Add("Pink Floyd", "performer")
+	noMasters := false
+	resp := runFakeCmd(t, d, &AudioOnlineRequest{
+		Cmd:     "release",
+		Release: r,
+		Search:  &SearchOptions{MaxCandidates: 10, MaxSuggestions: 10, SearchPages: 3, FetchMasters: &noMasters}})
+	require.NotNil(t, resp.SuggestionSet)
+	assert.Len(t, resp.SuggestionSet.Suggestions, 10)
+
+	var pages []string
+	for _, uri := range fake.Requests() {
+		assert.False(t, strings.HasPrefix(uri, "/masters/"), uri)
+		if strings.HasPrefix(uri, "/database/search") {
+			pages = append(pages, uri)
+		}
+	}
+	// результаты поиска умещаются на двух страницах
+	require.Len(t, pages, 2)
+	assert.Contains(t, pages[1], "page=2")
+
+	minFullResult := 2.
+	_, err = d.Execute(&AudioOnlineRequest{Cmd: "release", Release: r, Search: &SearchOptions{MinFullResult: &minFullResult}})
+	assert.True(t, errors.Is(err, ErrInvalidRequest))
+}
+
+func TestFakeExplain(t *testing.T) {
+	d, _ := newFakeDiscogs(t)
+
+	r := md.NewRelease()
+	r.Title = "The Dark Side Of The Moon"
+	r.ActorRoles.Add("Pink Floyd", "performer")
+	resp := runFakeCmd(t, d, &AudioOnlineRequest{Cmd: "release", Release: r, Explain: true})
+	require.NotEmpty(t, resp.SuggestionSet.Suggestions)
+	e := resp.Explanations["4139588"]
+	require.NotNil(t, e)
+	assert.Equal(t, resp.SuggestionSet.Suggestions[0].SourceSimilarity, e.Score)
+	require.Len(t, e.Fields, 2)
+	assert.Equal(t, "title", e.Fields[0].Field)
+	assert.Equal(t, "The Dark Side Of The Moon", e.Fields[0].Candidate)
+	assert.Equal(t, "artist", e.Fields[1].Field)
+
+	resp = runFakeCmd(t, d, &AudioOnlineRequest{Cmd: "release", Release: r})
+	assert.Nil(t, resp.Explanations)
+}
@@ -0,0 +1,48 @@
+package discogs
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/streadway/amqp"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+// progressFunc получает промежуточные результаты поиска релиза (см. Stage).
+// Может вызываться одновременно из нескольких горутин.
+type progressFunc func(stage Stage, suggestions []*md.Suggestion)
+
+func (p progressFunc) notify(stage Stage, suggestions ...*md.Suggestion) {
+	if p != nil {
+		p(stage, suggestions)
+	}
+}
+
+// Публикация промежуточных ответов на запрос `delivery`. Запрос подтверждается только
+// окончательным ответом (см. Answer), ошибки публикации промежуточных ответов выполнение
+// запроса не прерывают.
+func (d *Discogs) streamTo(delivery *amqp.Delivery) progressFunc {
+	var mu sync.Mutex
+	return func(stage Stage, suggestions []*md.Suggestion) {
+		set := md.NewSuggestionSet()
+		set.Suggestions = suggestions
+		data, err := json.Marshal(AudioOnlineResponse{SuggestionSet: set, Stage: stage})
+		if err != nil {
+			d.Log.WithError(err).Warn("Intermediate answer marshalling error")
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		err = d.publish(
+			delivery.ReplyTo,
+			amqp.Publishing{
+				ContentType:   "application/json",
+				CorrelationId: delivery.CorrelationId,
+				Body:          data,
+			})
+		if err != nil {
+			d.Log.WithError(err).Warn("Intermediate answer publishing error")
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package discogs
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+func TestStreamRelease(t *testing.T) {
+	d, _ := newFakeDiscogs(t)
+	ack := &testAcknowledger{}
+	var wg sync.WaitGroup
+	var cl *Client
+	cl = newClient(func(msg amqp.Publishing) error {
+		req := NewAudioOnlineRequest()
+		require.NoError(t, json.Unmarshal(msg.Body, req))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.RunCmd(req, &amqp.Delivery{
+				Acknowledger:  ack,
+				ReplyTo:       "client",
+				CorrelationId: msg.CorrelationId})
+		}()
+		return nil
+	})
+	d.publish = func(key string, msg amqp.Publishing) error {
+		assert.Equal(t, "client", key)
+		cl.deliver(msg.CorrelationId, msg.Body)
+		return nil
+	}
+	ctx := context.Background()
+
+	r := md.NewRelease()
+	r.Title = "The Dark Side Of The Moon"
+	r.ActorRoles.Add("Pink Floyd", "performer")
+	var stages []Stage
+	set, err := cl.ReleaseStream(ctx, r, func(resp *AudioOnlineResponse) {
+		stages = append(stages, resp.Stage)
+		require.NotNil(t, resp.SuggestionSet)
+		assert.NotEmpty(t, resp.SuggestionSet.Suggestions)
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, set.Suggestions)
+	assert.Equal(t, []Stage{StagePreliminary, StageSuggestion}, stages)
+
+	// ошибка запроса в потоковом режиме возвращается окончательным ответом
+	stages = nil
+	resp, err := cl.DoStream(ctx, &AudioOnlineRequest{Cmd: "release"}, func(resp *AudioOnlineResponse) {
+		stages = append(stages, resp.Stage)
+	})
+	require.Error(t, err)
+	assert.Equal(t, StageDone, resp.Stage)
+	assert.Empty(t, stages)
+
+	resp, err = cl.Do(ctx, &AudioOnlineRequest{Cmd: "release", Release: r})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Stage)
+
+	wg.Wait()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&ack.acks))
+}
@@ -0,0 +1,224 @@
+package discogs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/streadway/amqp"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+	srv "github.com/ytsiuryn/ds-microservice"
+)
+
+// Параметры обработки запросов RabbitMQ.
+const (
+	// Количество обработчиков запросов по умолчанию (см. WithWorkers).
+	DefaultWorkers = 4
+	// Количество неподтвержденных запросов, получаемых от брокера, на одного обработчика.
+	// Запас полученных запросов позволяет чередовать клиентов и выделять быстрые запросы.
+	PrefetchPerWorker = 4
+)
+
+// rpcJob - полученный от брокера запрос, ожидающий обработки.
+type rpcJob struct {
+	req      *AudioOnlineRequest
+	delivery amqp.Delivery
+}
+
+// scheduler распределяет полученные запросы между обработчиками. Быстрые запросы
+// (ping, info, ответы из кэша) выполняются в первую очередь, остальные - поочередно
+// для каждого клиента (очереди ответов ReplyTo), чтобы клиент с большим количеством
+// запросов не задерживал остальных.
+type scheduler struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	urgent []*rpcJob
+	queues map[string][]*rpcJob
+	order  []string
+	closed bool
+}
+
+func newScheduler() *scheduler {
+	s := &scheduler{queues: map[string][]*rpcJob{}}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *scheduler) push(job *rpcJob, urgent bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if urgent {
+		s.urgent = append(s.urgent, job)
+	} else {
+		client := job.delivery.ReplyTo
+		if len(s.queues[client]) == 0 {
+			s.order = append(s.order, client)
+		}
+		s.queues[client] = append(s.queues[client], job)
+	}
+	s.cond.Broadcast()
+}
+
+// pop блокирует выполнение до появления запроса. Если `urgentOnly` = true, ожидаются
+// только быстрые запросы. После закрытия планировщика возвращает false.
+func (s *scheduler) pop(urgentOnly bool) (*rpcJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		if s.closed {
+			return nil, false
+		}
+		if len(s.urgent) > 0 {
+			job := s.urgent[0]
+			s.urgent = s.urgent[1:]
+			return job, true
+		}
+		if !urgentOnly && len(s.order) > 0 {
+			client := s.order[0]
+			s.order = s.order[1:]
+			queue := s.queues[client]
+			job := queue[0]
+			if len(queue) > 1 {
+				s.queues[client] = queue[1:]
+				s.order = append(s.order, client)
+			} else {
+				delete(s.queues, client)
+			}
+			return job, true
+		}
+		s.cond.Wait()
+	}
+}
+
+// close завершает ожидание запросов обработчиками. Необработанные запросы остаются
+// неподтвержденными и возвращаются брокером в очередь при закрытии канала.
+func (s *scheduler) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.cond.Broadcast()
+}
+
+// Подключение к брокеру сообщений. В отличие от srv.Service.ConnectToMessageBroker
+// количество неподтвержденных запросов не ограничивается одним, что позволяет
+// обрабатывать запросы параллельно.
+func (d *Discogs) connect(connstr string) <-chan amqp.Delivery {
+	var err error
+
+	d.conn, err = amqp.Dial(connstr)
+	srv.FailOnError(err, "Failed to connect to RabbitMQ")
+
+	d.ch, err = d.conn.Channel()
+	srv.FailOnError(err, "Failed to open a channel")
+
+	q, err := d.ch.QueueDeclare(
+		d.Name, // name
+		false,  // durable
+		false,  // delete when unused
+		false,  // exclusive
+		false,  // no-wait
+		nil,    // arguments
+	)
+	srv.FailOnError(err, "Failed to declare a queue")
+
+	err = d.ch.Qos(
+		d.workers*PrefetchPerWorker, // prefetch count
+		0,                           // prefetch size
+		false,                       // global
+	)
+	srv.FailOnError(err, "Failed to set QoS")
+
+	msgs, err := d.ch.Consume(
+		q.Name, // queue
+		d.Name, // consumer
+		false,  // auto ack
+		false,  // exclusive
+		false,  // no local
+		false,  // no wait
+		nil,    // args
+	)
+	srv.FailOnError(err, "Failed to register a consumer")
+
+	d.publish = func(key string, msg amqp.Publishing) error {
+		return d.ch.Publish("", key, false, false, msg)
+	}
+	return msgs
+}
+
+// Распределение полученных запросов между `d.workers` обработчиками и дополнительным
+// обработчиком быстрых запросов.
+func (d *Discogs) serve(msgs <-chan amqp.Delivery) {
+	for i := 0; i <= d.workers; i++ {
+		urgentOnly := i == d.workers
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for {
+				job, ok := d.sched.pop(urgentOnly)
+				if !ok {
+					return
+				}
+				d.logRequest(job.delivery.CorrelationId, job.req)
+				d.RunCmd(job.req, &job.delivery)
+			}
+		}()
+	}
+	for delivery := range msgs {
+		req := NewAudioOnlineRequest()
+		if err := json.Unmarshal(delivery.Body, req); err != nil {
+			d.AnswerWithError(&delivery, fmt.Errorf("%w: %v", ErrInvalidRequest, err), "Message dispatcher")
+			continue
+		}
+		d.sched.push(&rpcJob{req: req, delivery: delivery}, d.urgent(req))
+	}
+	d.sched.close()
+}
+
+// Быстрыми считаются служебные (в т.ч. неизвестные) команды и запросы сущностей по ID, ответ
+// на которые не требует обращения к Discogs API: при работе с локальным источником данных или
+// при наличии актуального ответа в кэше. Поиск по неполным данным и по имени выполняется
+// в очереди клиента и при работе с локальным источником.
+// Проверка выполняется при распределении запросов и поэтому не читает записи кэша: мастер-релиз
+// релиза, найденного в кэше, может потребовать одного запроса к Discogs API.
+func (d *Discogs) urgent(req *AudioOnlineRequest) bool {
+	local := d.backend != d.web
+	switch {
+	case req.Cmd == "release":
+		if req.Release == nil || req.Release.IDs[md.DiscogsReleaseID] == "" {
+			return false
+		}
+		return local || d.api.Cached(d.web.url("releases/"+req.Release.IDs[md.DiscogsReleaseID], nil))
+	case req.Cmd == "artist":
+		if req.Actor == nil || req.Actor.IDs[md.DiscogsArtistID] == "" {
+			return false
+		}
+		return local || d.api.Cached(d.web.url("artists/"+req.Actor.IDs[md.DiscogsArtistID], nil))
+	case req.Cmd == "label":
+		if req.Label == nil || req.Label.IDs[md.DiscogsLabelID] == "" {
+			return false
+		}
+		return local ||
+			req.Pagination == nil && d.api.Cached(d.web.url("labels/"+req.Label.IDs[md.DiscogsLabelID], nil))
+	case req.Cmd == "master":
+		return local && req.Release != nil && masterID(req.Release) != ""
+	}
+	// служебные и неизвестные команды
+	return true
+}
+
+// Answer отправляет клиенту ответ `result` в JSON формате в соответствии с идентификатором
+// запроса CorrelationId и подтверждает получение запроса.
+// В случае ошибки отправки работа сервиса прекращается.
+func (d *Discogs) Answer(delivery *amqp.Delivery, result []byte) {
+	err := d.publish(
+		delivery.ReplyTo,
+		amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: delivery.CorrelationId,
+			Body:          result,
+		})
+	srv.FailOnError(err, "Answer's publishing error")
+
+	srv.FailOnError(delivery.Ack(false), "Acknowledge error")
+}
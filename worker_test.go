@@ -0,0 +1,183 @@
+package discogs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	md "github.com/ytsiuryn/ds-audiomd"
+)
+
+type testAcknowledger struct {
+	acks int32
+}
+
+func (a *testAcknowledger) Ack(tag uint64, multiple bool) error {
+	atomic.AddInt32(&a.acks, 1)
+	return nil
+}
+
+func (a *testAcknowledger) Nack(tag uint64, multiple, requeue bool) error { return nil }
+func (a *testAcknowledger) Reject(tag uint64, requeue bool) error         { return nil }
+
+func testJob(client, correlationID string) *rpcJob {
+	return &rpcJob{
+		req:      &AudioOnlineRequest{Cmd: "release"},
+		delivery: amqp.Delivery{ReplyTo: client, CorrelationId: correlationID},
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	s := newScheduler()
+	s.push(testJob("a", "a1"), false)
+	s.push(testJob("a", "a2"), false)
+	s.push(testJob("a", "a3"), false)
+	s.push(testJob("b", "b1"), false)
+	s.push(testJob("c", "ping"), true)
+
+	var order []string
+	for i := 0; i < 5; i++ {
+		job, ok := s.pop(false)
+		require.True(t, ok)
+		order = append(order, job.delivery.CorrelationId)
+	}
+	assert.Equal(t, []string{"ping", "a1", "b1", "a2", "a3"}, order)
+
+	done := make(chan bool)
+	go func() {
+		_, ok := s.pop(true)
+		done <- ok
+	}()
+	s.push(testJob("a", "a4"), false) // обработчик быстрых запросов его не получает
+	s.close()
+	assert.False(t, <-done)
+}
+
+func TestSchedulerDump(t *testing.T) {
+	d := New("", "", WithDump(newTestDump(t)))
+	search := func(client, correlationID string) *rpcJob {
+		r := md.NewRelease()
+		r.Title = "Stockholm"
+		r.ActorRoles.Add("The Persuader", "performer")
+		return &rpcJob{
+			req:      &AudioOnlineRequest{Cmd: "release", Release: r},
+			delivery: amqp.Delivery{ReplyTo: client, CorrelationId: correlationID},
+		}
+	}
+	byID := testJob("c", "c1")
+	byID.req.Release = md.NewRelease()
+	byID.req.Release.IDs[md.DiscogsReleaseID] = "1"
+	byName := &rpcJob{
+		req:      &AudioOnlineRequest{Cmd: "artist", Actor: &Actor{Name: "The Persuader"}},
+		delivery: amqp.Delivery{ReplyTo: "c", CorrelationId: "c2"},
+	}
+	master := &rpcJob{
+		req:      &AudioOnlineRequest{Cmd: "master", Release: md.NewRelease()},
+		delivery: amqp.Delivery{ReplyTo: "d", CorrelationId: "d1"},
+	}
+	master.req.Release.IDs[md.DiscogsMasterID] = "5427"
+
+	// запросы по ID к локальному источнику выполняются вне очереди, поиск - поочередно
+	// для каждого клиента
+	s := newScheduler()
+	for _, job := range []*rpcJob{
+		search("a", "a1"), search("a", "a2"), search("a", "a3"), search("b", "b1"), byName, byID, master,
+	} {
+		s.push(job, d.urgent(job.req))
+	}
+	var order []string
+	for i := 0; i < 7; i++ {
+		job, ok := s.pop(false)
+		require.True(t, ok)
+		order = append(order, job.delivery.CorrelationId)
+	}
+	assert.Equal(t, []string{"c1", "d1", "a1", "b1", "c2", "a2", "a3"}, order)
+}
+
+func TestServe(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/releases/4139588" {
+			<-release
+			http.ServeFile(w, r, filepath.Join("testdata", "release.json"))
+			return
+		}
+		http.ServeFile(w, r, filepath.Join("testdata", "master.json"))
+	}))
+	defer ts.Close()
+	defer unblock()
+
+	d := New("", "", WithBaseURL(ts.URL), WithWorkers(1))
+	d.api.limiter.setLimit(0)
+	answers := make(chan amqp.Publishing, 10)
+	d.publish = func(key string, msg amqp.Publishing) error {
+		answers <- msg
+		return nil
+	}
+
+	ack := &testAcknowledger{}
+	msgs := make(chan amqp.Delivery)
+	served := make(chan struct{})
+	go func() {
+		d.serve(msgs)
+		close(served)
+	}()
+	send := func(correlationID string, req *AudioOnlineRequest) {
+		body, err := json.Marshal(req)
+		require.NoError(t, err)
+		msgs <- amqp.Delivery{
+			Acknowledger:  ack,
+			ReplyTo:       "client",
+			CorrelationId: correlationID,
+			Body:          body}
+	}
+
+	r := NewAudioOnlineRequest()
+	r.Cmd = "release"
+	r.Release.IDs[md.DiscogsReleaseID] = "4139588"
+	send("slow1", r)
+	send("slow2", r)
+	send("ping", &AudioOnlineRequest{Cmd: "ping"})
+	send("x", &AudioOnlineRequest{Cmd: "x"})
+
+	// обработчик занят медленным запросом, служебные команды выполняются без ожидания
+	for _, id := range []string{"ping", "x"} {
+		select {
+		case answer := <-answers:
+			assert.Equal(t, id, answer.CorrelationId)
+			if id == "x" {
+				resp := &AudioOnlineResponse{}
+				require.NoError(t, json.Unmarshal(answer.Body, resp))
+				require.NotNil(t, resp.Error)
+				assert.Equal(t, "unknown command: x", resp.Error.Error)
+				assert.Equal(t, CodeInvalidRequest, resp.Error.Code)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("urgent request is not answered")
+		}
+	}
+
+	unblock()
+	for _, id := range []string{"slow1", "slow2"} {
+		answer := <-answers
+		assert.Equal(t, id, answer.CorrelationId)
+		resp, err := ParseReleaseAnswer(answer.Body)
+		require.NoError(t, err)
+		assert.Nil(t, resp.Error)
+	}
+	close(msgs)
+	<-served
+	d.wg.Wait()
+	assert.Equal(t, int32(4), atomic.LoadInt32(&ack.acks))
+}